@@ -0,0 +1,210 @@
+package axcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Metric struct {
+	Func  string // count | sum | avg | min | max
+	Field string // для count можно не указывать — COUNT(*)
+	As    string // алиас в результате; по умолчанию "<func>_<field>" или "count"
+}
+
+type DateBucket struct {
+	Field string // колонка с датой/временем
+	Unit  string // hour | day | month | year
+	As    string // алиас; по умолчанию "<field>_<unit>"
+}
+
+// AggregateParams — метрики по тому же набору, что и GetList (Filters/Search из ListParams;
+// Sort и Pagination игнорируются).
+type AggregateParams struct {
+	ListParams
+	Metrics []Metric
+	GroupBy []string
+	Bucket  *DateBucket
+}
+
+// AggregateRow — одна группа: значения group-by колонок, бакета и метрик по алиасам
+type AggregateRow map[string]any
+
+var aliasRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (r *GormRepo[T, ID]) Aggregate(ctx context.Context, p AggregateParams) ([]AggregateRow, error) {
+	ctx, cancel := r.withTimeout(ctx, queryList)
+	defer cancel()
+	if len(p.Metrics) == 0 {
+		return nil, errors.New("at least one metric is required")
+	}
+	q, err := r.applyWhere(ctx, r.base(ctx), p.ListParams)
+	if err != nil {
+		return nil, err
+	}
+
+	selects := make([]string, 0, len(p.GroupBy)+len(p.Metrics)+1)
+	groups := make([]string, 0, len(p.GroupBy)+1)
+	for _, g := range p.GroupBy {
+		if !r.cfg.AllowedGroupFields.Has(g) {
+			return nil, fmt.Errorf("grouping by field '%s' is not allowed", g)
+		}
+		if err := r.checkReadable(ctx, g); err != nil {
+			return nil, err
+		}
+		selects = append(selects, g)
+		groups = append(groups, g)
+	}
+	if b := p.Bucket; b != nil {
+		if !r.cfg.AllowedGroupFields.Has(b.Field) {
+			return nil, fmt.Errorf("grouping by field '%s' is not allowed", b.Field)
+		}
+		if err := r.checkReadable(ctx, b.Field); err != nil {
+			return nil, err
+		}
+		expr, err := dateTrunc(q.Dialector.Name(), b.Field, strings.ToLower(b.Unit))
+		if err != nil {
+			return nil, err
+		}
+		alias := b.As
+		if alias == "" {
+			alias = b.Field + "_" + strings.ToLower(b.Unit)
+		}
+		if !aliasRe.MatchString(alias) {
+			return nil, fmt.Errorf("invalid alias '%s'", alias)
+		}
+		selects = append(selects, expr+" AS "+alias)
+		groups = append(groups, expr)
+	}
+	for _, m := range p.Metrics {
+		expr, alias, err := r.metricExpr(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, expr+" AS "+alias)
+	}
+
+	q = q.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		q = q.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", "))
+	}
+	var rows []map[string]any
+	if err = q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]AggregateRow, len(rows))
+	for i := range rows {
+		out[i] = rows[i]
+	}
+	return out, nil
+}
+
+func (r *GormRepo[T, ID]) metricExpr(ctx context.Context, m Metric) (expr, alias string, err error) {
+	fn := strings.ToLower(strings.TrimSpace(m.Func))
+	field := strings.TrimSpace(m.Field)
+	switch fn {
+	case "count", "sum", "avg", "min", "max":
+	default:
+		return "", "", fmt.Errorf("unsupported aggregate function: %s", m.Func)
+	}
+	switch {
+	case fn == "count" && field == "":
+		expr, alias = "COUNT(*)", "count"
+	case field == "":
+		return "", "", fmt.Errorf("aggregate %s requires a field", fn)
+	case !r.cfg.AllowedAggregateFields.Has(field):
+		return "", "", fmt.Errorf("aggregating field '%s' is not allowed", field)
+	case !r.canReadField(ctx, field):
+		return "", "", fmt.Errorf("%w: field '%s' is not readable", ErrForbidden, field)
+	default:
+		expr, alias = fmt.Sprintf("%s(%s)", strings.ToUpper(fn), field), fn+"_"+field
+	}
+	if m.As != "" {
+		alias = m.As
+	}
+	if !aliasRe.MatchString(alias) {
+		return "", "", fmt.Errorf("invalid alias '%s'", alias)
+	}
+	return expr, alias, nil
+}
+
+// форматы усечения для strftime (SQLite) и DATE_FORMAT (MySQL) совпадают
+var truncLayouts = map[string]string{
+	"hour":  "%Y-%m-%d %H:00:00",
+	"day":   "%Y-%m-%d",
+	"month": "%Y-%m-01",
+	"year":  "%Y-01-01",
+}
+
+// dateTrunc — усечение даты до начала часа/дня/месяца/года для разных диалектов
+func dateTrunc(dialect, col, unit string) (string, error) {
+	layout, ok := truncLayouts[unit]
+	if !ok {
+		return "", fmt.Errorf("unsupported date bucket: %s", unit)
+	}
+	switch dialect {
+	case "postgres":
+		return fmt.Sprintf("date_trunc('%s', %s)", unit, col), nil
+	case "mysql":
+		return fmt.Sprintf("DATE_FORMAT(%s, '%s')", col, layout), nil
+	case "sqlite":
+		return fmt.Sprintf("strftime('%s', %s)", layout, col), nil
+	default:
+		return "", fmt.Errorf("date buckets are not supported for dialect %s", dialect)
+	}
+}
+
+const defaultMaxFacetValues = 100
+
+type FacetValue struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// Facets — distinct-значения с количеством по каждому полю из fields.
+// Как принято в фасетном поиске, собственный фильтр поля при подсчёте его фасета не применяется.
+func (r *GormRepo[T, ID]) Facets(ctx context.Context, p ListParams, fields []string) (map[string][]FacetValue, error) {
+	ctx, cancel := r.withTimeout(ctx, queryList)
+	defer cancel()
+	limit := r.cfg.MaxFacetValues
+	if limit <= 0 {
+		limit = defaultMaxFacetValues
+	}
+	out := make(map[string][]FacetValue, len(fields))
+	for _, field := range fields {
+		if !r.cfg.AllowedFacetFields.Has(field) {
+			return nil, fmt.Errorf("facets for field '%s' are not allowed", field)
+		}
+		if err := r.checkReadable(ctx, field); err != nil {
+			return nil, err
+		}
+		others := make([]Filter, 0, len(p.Filters))
+		for _, f := range p.Filters {
+			if strings.TrimSpace(f.Field) != field {
+				others = append(others, f)
+			}
+		}
+		q, err := r.applyWhere(ctx, r.base(ctx), ListParams{Filters: others, Search: p.Search, SearchFields: p.SearchFields})
+		if err != nil {
+			return nil, err
+		}
+		var rows []map[string]any
+		err = q.Select(field + " AS value, COUNT(*) AS count").
+			Group(field).
+			Order("count DESC, " + field).
+			Limit(limit).
+			Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		values := make([]FacetValue, 0, len(rows))
+		for _, row := range rows {
+			n, _ := row["count"].(int64)
+			values = append(values, FacetValue{Value: row["value"], Count: n})
+		}
+		out[field] = values
+	}
+	return out, nil
+}
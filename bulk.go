@@ -0,0 +1,486 @@
+package axcrud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const defaultBatchSize = 100
+
+// BatchError — пакетная запись (CreateMany/UpsertMany) упала на пачке Index:
+// элементы items[Offset:Offset+Size]. Вся операция при этом откатывается.
+type BatchError struct {
+	Index  int
+	Offset int
+	Size   int
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d (items %d-%d): %v", e.Index, e.Offset, e.Offset+e.Size-1, e.Err)
+}
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// insertBatches — как CreateInBatches (все пачки в одной транзакции), но ошибка — BatchError
+func insertBatches[T any](q *gorm.DB, items []T, batchSize int) error {
+	if q.Error != nil { // Restrict/ContextScopes — не ошибка пачки
+		return q.Error
+	}
+	return q.Transaction(func(tx *gorm.DB) error {
+		for i, start := 0, 0; start < len(items); i, start = i+1, start+batchSize {
+			part := items[start:min(start+batchSize, len(items))]
+			if err := tx.Create(&part).Error; err != nil {
+				return &BatchError{Index: i, Offset: start, Size: len(part), Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+// CreateMany вставляет записи пачками по batchSize (INSERT ... VALUES (...), (...)).
+// PK проставляются прямо в items; они же возвращаются в исходном порядке.
+func (r *GormRepo[T, ID]) CreateMany(ctx context.Context, items []T, batchSize int) ([]ID, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) ([]ID, error) {
+		return r.createMany(ctx, items, batchSize)
+	})
+}
+
+func (r *GormRepo[T, ID]) createMany(ctx context.Context, items []T, batchSize int) ([]ID, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if err := r.checkWriteSlice(ctx, items); err != nil {
+		return nil, err
+	}
+	if err := r.stampTenantAll(ctx, items); err != nil {
+		return nil, err
+	}
+	if err := insertBatches(r.baseFor(ctx, OpCreate), items, batchSize); err != nil {
+		return nil, err
+	}
+	ids, err := r.idsOf(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	r.maskSlice(ctx, items)
+	return ids, r.recordChange(ctx, EventCreated, ids)
+}
+
+func (r *GormRepo[T, ID]) Upsert(ctx context.Context, in *T, p UpsertParams) error {
+	return r.writeTx(ctx, func(ctx context.Context) error {
+		return r.upsert(ctx, in, p)
+	})
+}
+
+func (r *GormRepo[T, ID]) upsert(ctx context.Context, in *T, p UpsertParams) error {
+	if err := r.checkWriteObj(ctx, in); err != nil {
+		return err
+	}
+	if err := r.stampTenant(ctx, in); err != nil {
+		return err
+	}
+	if err := r.checkUpsertTenant(ctx, []T{*in}, p); err != nil {
+		return err
+	}
+	if err := r.authorizeUpsert(ctx, []T{*in}, p); err != nil {
+		return err
+	}
+	if err := r.baseFor(ctx, OpCreate).Clauses(r.onConflict(ctx, p)).Create(in).Error; err != nil {
+		return err
+	}
+	r.maskFields(ctx, in)
+	if !r.tracksChanges() {
+		return nil
+	}
+	id, err := r.idOf(ctx, in)
+	if err != nil {
+		return err
+	}
+	return r.recordChange(ctx, EventUpdated, []ID{id})
+}
+
+// UpsertMany — пакетный INSERT ... ON CONFLICT DO UPDATE.
+// ID берутся из RETURNING, поэтому для MySQL у обновлённых строк они не гарантируются.
+func (r *GormRepo[T, ID]) UpsertMany(ctx context.Context, items []T, p UpsertParams) ([]ID, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) ([]ID, error) {
+		return r.upsertMany(ctx, items, p)
+	})
+}
+
+func (r *GormRepo[T, ID]) upsertMany(ctx context.Context, items []T, p UpsertParams) ([]ID, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if err := r.checkWriteSlice(ctx, items); err != nil {
+		return nil, err
+	}
+	if err := r.stampTenantAll(ctx, items); err != nil {
+		return nil, err
+	}
+	if err := r.checkUpsertTenant(ctx, items, p); err != nil {
+		return nil, err
+	}
+	if err := r.authorizeUpsert(ctx, items, p); err != nil {
+		return nil, err
+	}
+	if err := insertBatches(r.baseFor(ctx, OpCreate).Clauses(r.onConflict(ctx, p)), items, batchSize); err != nil {
+		return nil, err
+	}
+	ids, err := r.idsOf(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	r.maskSlice(ctx, items)
+	// вставка или обновление — для подписчика разницы нет, запись надо перечитать
+	return ids, r.recordChange(ctx, EventUpdated, ids)
+}
+
+// UpdateMany применяет patch ко всем записям с указанными ID (в пределах Scopes).
+func (r *GormRepo[T, ID]) UpdateMany(ctx context.Context, ids []ID, patch map[string]any) (int64, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) (int64, error) {
+		return r.updateMany(ctx, ids, patch)
+	})
+}
+
+func (r *GormRepo[T, ID]) updateMany(ctx context.Context, ids []ID, patch map[string]any) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := r.checkPatch(ctx, patch); err != nil {
+		return 0, err
+	}
+	if err := r.authorizeMany(ctx, OpUpdate, ids); err != nil {
+		return 0, err
+	}
+	q := r.baseFor(ctx, OpUpdate).
+		Where(clause.IN{Column: clause.Column{Name: r.idCol}, Values: toAnySlice(ids)})
+	// чужие (Scopes, тенант, Restrict) и несуществующие ID в событие не попадают
+	changed, err := r.matchingIDs(q)
+	if err != nil {
+		return 0, err
+	}
+	tx := q.Updates(patch)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		if err := r.recordChange(ctx, EventUpdated, changed); err != nil {
+			return 0, err
+		}
+	}
+	return tx.RowsAffected, nil
+}
+
+// UpdateWhere применяет patch ко всем записям, подходящим под фильтры (whitelist как в GetList).
+// Пустой набор фильтров запрещён — иначе обновится вся таблица.
+func (r *GormRepo[T, ID]) UpdateWhere(ctx context.Context, filters []Filter, patch map[string]any) (int64, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) (int64, error) {
+		return r.updateWhere(ctx, filters, patch)
+	})
+}
+
+func (r *GormRepo[T, ID]) updateWhere(ctx context.Context, filters []Filter, patch map[string]any) (int64, error) {
+	// [{}] — тоже пустой фильтр: applyFilters его пропустит, и UPDATE затронет всю таблицу
+	if countFilters(filters) == 0 {
+		return 0, ErrEmptyFilters
+	}
+	if err := r.checkPatch(ctx, patch); err != nil {
+		return 0, err
+	}
+	q, err := r.applyFilters(ctx, r.baseFor(ctx, OpUpdate), filters)
+	if err != nil {
+		return 0, err
+	}
+	if err = r.authorizeRows(ctx, OpUpdate, q); err != nil {
+		return 0, err
+	}
+	ids, err := r.matchingIDs(q)
+	if err != nil {
+		return 0, err
+	}
+	tx := q.Updates(patch)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		if err := r.recordChange(ctx, EventUpdated, ids); err != nil {
+			return 0, err
+		}
+	}
+	return tx.RowsAffected, nil
+}
+
+type DeleteWhereOptions struct {
+	// Разрешить пустой набор фильтров — удалить всё в пределах Scopes
+	Force bool
+	// >0: если удаляется больше строк — откат и ErrMaxAffectedExceeded
+	MaxAffected int64
+	// Ничего не удалять, только вернуть количество подходящих строк
+	DryRun bool
+}
+
+// DeleteWhere удаляет записи по фильтрам (whitelist как в GetList).
+func (r *GormRepo[T, ID]) DeleteWhere(ctx context.Context, filters []Filter, opts DeleteWhereOptions) (int64, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) (int64, error) {
+		return r.deleteWhere(ctx, filters, opts)
+	})
+}
+
+func (r *GormRepo[T, ID]) deleteWhere(ctx context.Context, filters []Filter, opts DeleteWhereOptions) (int64, error) {
+	empty := countFilters(filters) == 0
+	if empty && !opts.Force {
+		return 0, ErrEmptyFilters
+	}
+	prepare := func(db *gorm.DB) (*gorm.DB, error) {
+		q := r.restrict(ctx, OpDelete, r.scoped(ctx, db))
+		if r.cfg.UnscopedDelete {
+			q = q.Unscoped()
+		}
+		if empty {
+			q = q.Session(&gorm.Session{AllowGlobalUpdate: true})
+		}
+		return r.applyFilters(ctx, q, filters)
+	}
+
+	if opts.DryRun {
+		q, err := prepare(r.conn(ctx))
+		if err != nil {
+			return 0, err
+		}
+		var total int64
+		err = q.Count(&total).Error
+		return total, err
+	}
+
+	var affected int64
+	var ids []ID
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		q, err := prepare(tx)
+		if err != nil {
+			return err
+		}
+		if err = r.authorizeRows(ctx, OpDelete, q); err != nil {
+			return err
+		}
+		if ids, err = r.matchingIDs(q); err != nil {
+			return err
+		}
+		var z T
+		res := q.Delete(&z)
+		if res.Error != nil {
+			return res.Error
+		}
+		if opts.MaxAffected > 0 && res.RowsAffected > opts.MaxAffected {
+			return fmt.Errorf("%w: %d > %d", ErrMaxAffectedExceeded, res.RowsAffected, opts.MaxAffected)
+		}
+		affected = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		if err = r.recordChange(ctx, EventDeleted, ids); err != nil {
+			return 0, err
+		}
+	}
+	return affected, nil
+}
+
+// conflictColumns — колонки ON CONFLICT; по умолчанию PK
+func (r *GormRepo[T, ID]) conflictColumns(p UpsertParams) []string {
+	if len(p.ConflictColumns) > 0 {
+		return p.ConflictColumns
+	}
+	if len(r.cfg.UpsertConflictColumns) > 0 {
+		return r.cfg.UpsertConflictColumns
+	}
+	return []string{r.idCol}
+}
+
+// upsertConflicts — существующие строки (включая чужие по Scopes и мягко удалённые),
+// с которыми столкнутся items по колонкам конфликта
+func (r *GormRepo[T, ID]) upsertConflicts(ctx context.Context, items []T, p UpsertParams) ([]T, error) {
+	if r.schema == nil {
+		return nil, errors.New("model schema is not available")
+	}
+	cols := r.conflictColumns(p)
+	fields := make([]*schema.Field, len(cols))
+	for i, c := range cols {
+		if fields[i] = r.schema.LookUpField(c); fields[i] == nil {
+			return nil, fmt.Errorf("conflict column '%s' not found", c)
+		}
+	}
+	var conds []clause.Expression
+	for i := range items {
+		eqs := make([]clause.Expression, 0, len(cols))
+		allZero := true
+		for _, f := range fields {
+			v, zero := f.ValueOf(ctx, reflect.ValueOf(&items[i]).Elem())
+			allZero = allZero && zero
+			eqs = append(eqs, clause.Eq{Column: clause.Column{Name: f.DBName}, Value: v})
+		}
+		if !allZero { // пустой ключ (обычно PK) — это вставка
+			conds = append(conds, clause.And(eqs...))
+		}
+	}
+	var out []T
+	for len(conds) > 0 {
+		n := min(len(conds), defaultIDChunkSize)
+		var part []T
+		if err := r.conn(ctx).Unscoped().Model(new(T)).Where(clause.Or(conds[:n]...)).Find(&part).Error; err != nil {
+			return nil, err
+		}
+		out = append(out, part...)
+		conds = conds[n:]
+	}
+	return out, nil
+}
+
+// authorizeUpsert — ON CONFLICT DO UPDATE меняет существующие строки: с политикой каждая из них
+// должна проходить Restrict(OpUpdate) и CanUpdate, как при Update
+func (r *GormRepo[T, ID]) authorizeUpsert(ctx context.Context, items []T, p UpsertParams) error {
+	if r.policy == nil {
+		return nil
+	}
+	existing, err := r.upsertConflicts(ctx, items, p)
+	if err != nil || len(existing) == 0 {
+		return err
+	}
+	ids, err := r.idsOf(ctx, existing)
+	if err != nil {
+		return err
+	}
+	var allowed []T
+	err = r.baseFor(ctx, OpUpdate).
+		Where(clause.IN{Column: clause.Column{Name: r.idCol}, Values: toAnySlice(ids)}).
+		Find(&allowed).Error
+	if err != nil {
+		return err
+	}
+	visible := make(map[ID]T, len(allowed))
+	for i := range allowed {
+		id, err := r.idOf(ctx, &allowed[i])
+		if err != nil {
+			return err
+		}
+		visible[id] = allowed[i]
+	}
+	for _, id := range ids {
+		obj, ok := visible[id]
+		if !ok || !r.can(ctx, OpUpdate, obj) {
+			return fmt.Errorf("%w: %s %v", ErrForbidden, OpUpdate, id)
+		}
+	}
+	return nil
+}
+
+func (r *GormRepo[T, ID]) onConflict(ctx context.Context, p UpsertParams) clause.OnConflict {
+	update := p.UpdateColumns
+	if len(update) == 0 {
+		update = r.cfg.UpsertUpdateColumns
+	}
+
+	oc := clause.OnConflict{}
+	for _, c := range r.conflictColumns(p) {
+		oc.Columns = append(oc.Columns, clause.Column{Name: c})
+	}
+	tenantCol := r.cfg.TenantColumn
+	switch {
+	case len(update) > 0:
+		// колонку тенанта ON CONFLICT не переписывает никогда
+		cols := make([]string, 0, len(update))
+		for _, c := range update {
+			if c != tenantCol {
+				cols = append(cols, c)
+			}
+		}
+		oc.DoUpdates = clause.AssignmentColumns(cols)
+	case tenantCol != "" && r.schema != nil:
+		oc.DoUpdates = clause.AssignmentColumns(r.upsertAllColumns())
+	default:
+		// все колонки, кроме PK и created_at
+		oc.UpdateAll = true
+	}
+	// конфликт с записью другого тенанта её не обновляет (Postgres/SQLite; для остальных — checkUpsertTenant)
+	if tenantCol != "" {
+		if tenantID, ok := TenantIDFromContext(ctx); ok {
+			oc.Where = clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantCol}, Value: tenantID},
+			}}
+		}
+	}
+	return oc
+}
+
+// upsertAllColumns — как UpdateAll в GORM (все вставляемые колонки, кроме PK, created_at
+// и колонок с DB-default), но без колонки тенанта
+func (r *GormRepo[T, ID]) upsertAllColumns() []string {
+	var cols []string
+	for _, f := range r.schema.Fields {
+		if f.DBName == "" || f.PrimaryKey || !f.Creatable || !f.Updatable || f.AutoCreateTime > 0 ||
+			f.DBName == r.cfg.TenantColumn {
+			continue
+		}
+		if f.HasDefaultValue && f.DefaultValueInterface == nil && !strings.EqualFold(f.DefaultValue, "NULL") {
+			continue
+		}
+		cols = append(cols, f.DBName)
+	}
+	return cols
+}
+
+// conflictWhereSupported — диалект понимает ON CONFLICT ... DO UPDATE ... WHERE
+func (r *GormRepo[T, ID]) conflictWhereSupported() bool {
+	switch r.db.Dialector.Name() {
+	case "postgres", "sqlite":
+		return true
+	}
+	return false
+}
+
+// checkUpsertTenant — без ON CONFLICT ... WHERE (MySQL и др.) конфликт с записью другого тенанта
+// обновил бы её, поэтому такие конфликты ищутся заранее
+func (r *GormRepo[T, ID]) checkUpsertTenant(ctx context.Context, items []T, p UpsertParams) error {
+	col := r.cfg.TenantColumn
+	if col == "" || r.conflictWhereSupported() {
+		return nil
+	}
+	tenantID, ok := TenantIDFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	existing, err := r.upsertConflicts(ctx, items, p)
+	if err != nil {
+		return err
+	}
+	field := r.schema.LookUpField(col)
+	if field == nil {
+		return fmt.Errorf("tenant column '%s' not found", col)
+	}
+	for i := range existing {
+		v, _ := field.ValueOf(ctx, reflect.ValueOf(&existing[i]).Elem())
+		if fmt.Sprint(v) != fmt.Sprint(tenantID) {
+			id, err := r.idOf(ctx, &existing[i])
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s %v", ErrForbidden, OpUpdate, id)
+		}
+	}
+	return nil
+}
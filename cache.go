@@ -0,0 +1,491 @@
+package axcrud
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Cache — хранилище для CachedRepo; ttl <= 0 — без срока (до вытеснения)
+type Cache interface {
+	Get(key string) (any, bool)
+	Set(key string, v any, ttl time.Duration)
+	Delete(key string)
+}
+
+// LRUCache — Cache в памяти процесса: не больше size записей, вытесняются давно не читанные
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = 10000
+	}
+	return &LRUCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *LRUCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *LRUCache) Set(key string, v any, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value = &lruEntry{key: key, value: v, expires: expires}
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: v, expires: expires})
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*lruEntry).key)
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+type CacheOptions struct {
+	// Префикс ключей; по умолчанию имя типа T
+	Prefix string
+	// Время жизни записи; по умолчанию минута
+	TTL time.Duration
+	// Кэшировать GetList (ключ — нормализованные ListParams); сбрасывается любой записью через декоратор
+	CacheLists bool
+	// Время жизни списка; по умолчанию TTL
+	ListTTL time.Duration
+	// Часть ключа, зависящая от вызывающего; по умолчанию тенант + Principal (ID и роли),
+	// так как Policy и FieldAccess дают разным пользователям разные данные
+	ContextKey func(ctx context.Context) string
+}
+
+// CachedRepo — read-through кэш GetOne/GetMany/GetManyOrdered (и, опционально, GetList) поверх любого Repo.
+// Записи через декоратор сбрасывают затронутые ключи; изменения в обход декоратора видны только по истечении TTL.
+// Внутри транзакции (ctx с TxFromContext или WithTx) кэш не читается и не заполняется.
+// Значения отдаются как есть — вложенные срезы/указатели общие для всех читателей, менять их нельзя.
+type CachedRepo[T any, ID IDConstraint] struct {
+	inner Repo[T, ID]
+	cache Cache
+	opts  CacheOptions
+	tx    bool
+}
+
+func NewCachedRepo[T any, ID IDConstraint](inner Repo[T, ID], cache Cache, opts CacheOptions) *CachedRepo[T, ID] {
+	if opts.Prefix == "" {
+		opts.Prefix = reflect.TypeOf((*T)(nil)).Elem().String()
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.ListTTL <= 0 {
+		opts.ListTTL = opts.TTL
+	}
+	if opts.ContextKey == nil {
+		opts.ContextKey = defaultCacheContextKey
+	}
+	return &CachedRepo[T, ID]{inner: inner, cache: cache, opts: opts}
+}
+
+func defaultCacheContextKey(ctx context.Context) string {
+	var b strings.Builder
+	if t, ok := TenantIDFromContext(ctx); ok {
+		fmt.Fprintf(&b, "t=%v", t)
+	}
+	if p, ok := PrincipalFromContext(ctx); ok {
+		roles := append([]string(nil), p.Roles...)
+		sort.Strings(roles)
+		fmt.Fprintf(&b, ";p=%v;r=%s", p.ID, strings.Join(roles, ","))
+	}
+	return b.String()
+}
+
+var cacheStampSeq atomic.Uint64
+
+func init() {
+	cacheStampSeq.Store(uint64(time.Now().UnixNano()))
+}
+
+// Поколения (stamp) входят в ключи: сброс = новое значение поколения, старые ключи просто перестают читаться.
+// Вытесненное поколение заменяется новым, поэтому устаревшие записи не «оживают».
+func (c *CachedRepo[T, ID]) stamp(key string) string {
+	if v, ok := c.cache.Get(key); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return c.bump(key)
+}
+
+func (c *CachedRepo[T, ID]) bump(key string) string {
+	s := strconv.FormatUint(cacheStampSeq.Add(1), 36)
+	c.cache.Set(key, s, 0)
+	return s
+}
+
+func (c *CachedRepo[T, ID]) globalKey() string {
+	return c.opts.Prefix + "|gen"
+}
+
+func (c *CachedRepo[T, ID]) listGenKey() string {
+	return c.opts.Prefix + "|lists"
+}
+
+func (c *CachedRepo[T, ID]) recordGenKey(id ID) string {
+	return c.opts.Prefix + "|rec|" + fmt.Sprint(id)
+}
+
+func (c *CachedRepo[T, ID]) oneKey(ctx context.Context, gen string, id ID) string {
+	return fmt.Sprintf("%s|one|%s|%s|%s|%v", c.opts.Prefix, gen, c.stamp(c.recordGenKey(id)), c.opts.ContextKey(ctx), id)
+}
+
+func (c *CachedRepo[T, ID]) bypass(ctx context.Context) bool {
+	if c.tx {
+		return true
+	}
+	_, ok := TxFromContext(ctx)
+	return ok
+}
+
+// invalidate сбрасывает записи ids (nil — все записи) и списки; в транзакции — ещё раз после commit,
+// чтобы параллельное чтение не закэшировало старое значение до фиксации
+func (c *CachedRepo[T, ID]) invalidate(ctx context.Context, ids []ID, all bool) {
+	fn := func() {
+		if all {
+			c.bump(c.globalKey())
+		}
+		for _, id := range ids {
+			c.bump(c.recordGenKey(id))
+		}
+		c.bump(c.listGenKey())
+	}
+	fn()
+	if _, ok := TxFromContext(ctx); ok {
+		AfterCommit(ctx, fn)
+	}
+}
+
+func (c *CachedRepo[T, ID]) GetOne(ctx context.Context, id ID) (T, error) {
+	if c.bypass(ctx) {
+		return c.inner.GetOne(ctx, id)
+	}
+	key := c.oneKey(ctx, c.stamp(c.globalKey()), id)
+	if v, ok := c.cache.Get(key); ok {
+		return v.(T), nil
+	}
+	item, err := c.inner.GetOne(ctx, id)
+	if err != nil {
+		return item, err
+	}
+	c.cache.Set(key, item, c.opts.TTL)
+	return item, nil
+}
+
+func (c *CachedRepo[T, ID]) GetMany(ctx context.Context, ids []ID) ([]T, error) {
+	res, err := c.GetManyOrdered(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// GetManyOrdered — из кэша берутся найденные, остальные догружаются одним запросом
+func (c *CachedRepo[T, ID]) GetManyOrdered(ctx context.Context, ids []ID) (ManyResult[T, ID], error) {
+	if c.bypass(ctx) {
+		return c.inner.GetManyOrdered(ctx, ids)
+	}
+	gen := c.stamp(c.globalKey())
+	seen := make(map[ID]struct{}, len(ids))
+	uniq := make([]ID, 0, len(ids))
+	keys := make(map[ID]string, len(ids))
+	found := make(map[ID]T, len(ids))
+	var miss []ID
+	for _, id := range ids {
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		uniq = append(uniq, id)
+		keys[id] = c.oneKey(ctx, gen, id)
+		if v, ok := c.cache.Get(keys[id]); ok {
+			found[id] = v.(T)
+		} else {
+			miss = append(miss, id)
+		}
+	}
+	missing := map[ID]struct{}{}
+	if len(miss) > 0 {
+		res, err := c.inner.GetManyOrdered(ctx, miss)
+		if err != nil {
+			return ManyResult[T, ID]{}, err
+		}
+		for _, id := range res.Missing {
+			missing[id] = struct{}{}
+		}
+		// Items идут в порядке miss без ненайденных
+		i := 0
+		for _, id := range miss {
+			if _, ok := missing[id]; ok || i >= len(res.Items) {
+				continue
+			}
+			found[id] = res.Items[i]
+			c.cache.Set(keys[id], res.Items[i], c.opts.TTL)
+			i++
+		}
+	}
+	out := ManyResult[T, ID]{Items: make([]T, 0, len(found))}
+	for _, id := range uniq {
+		if item, ok := found[id]; ok {
+			out.Items = append(out.Items, item)
+		} else {
+			out.Missing = append(out.Missing, id)
+		}
+	}
+	return out, nil
+}
+
+type cachedList[T any] struct {
+	items []T
+	total int64
+}
+
+func (c *CachedRepo[T, ID]) GetList(ctx context.Context, p ListParams) ([]T, int64, error) {
+	if !c.opts.CacheLists || c.bypass(ctx) {
+		return c.inner.GetList(ctx, p)
+	}
+	norm, err := normalizeListParams(p)
+	if err != nil {
+		return c.inner.GetList(ctx, p)
+	}
+	key := fmt.Sprintf("%s|list|%s|%s|%s|%s", c.opts.Prefix, c.stamp(c.globalKey()), c.stamp(c.listGenKey()), c.opts.ContextKey(ctx), norm)
+	if v, ok := c.cache.Get(key); ok {
+		l := v.(cachedList[T])
+		return l.items, l.total, nil
+	}
+	items, total, err := c.inner.GetList(ctx, p)
+	if err != nil {
+		return items, total, err
+	}
+	c.cache.Set(key, cachedList[T]{items: items, total: total}, c.opts.ListTTL)
+	return items, total, nil
+}
+
+// normalizeListParams — хэш ListParams, не зависящий от порядка фильтров, полей поиска и регистра операторов
+func normalizeListParams(p ListParams) (string, error) {
+	type nf struct {
+		F, O string
+		V    json.RawMessage
+	}
+	filters := make([]nf, 0, len(p.Filters))
+	for _, f := range p.Filters {
+		v, err := json.Marshal(f.Value)
+		if err != nil {
+			return "", err
+		}
+		filters = append(filters, nf{F: strings.TrimSpace(f.Field), O: strings.ToLower(strings.TrimSpace(f.Operator)), V: v})
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		a, b := filters[i], filters[j]
+		if a.F != b.F {
+			return a.F < b.F
+		}
+		if a.O != b.O {
+			return a.O < b.O
+		}
+		return string(a.V) < string(b.V)
+	})
+	fields := append([]string(nil), p.SearchFields...)
+	sort.Strings(fields)
+	var s *Sort
+	if p.Sort != nil {
+		s = &Sort{Field: p.Sort.Field, Order: strings.ToLower(p.Sort.Order)}
+	}
+	raw, err := json.Marshal(struct {
+		Filters []nf
+		Sort    *Sort
+		Search  string
+		Fields  []string
+		Page    Pagination
+	}{filters, s, strings.TrimSpace(p.Search), fields, p.Pagination})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+func (c *CachedRepo[T, ID]) FindOne(ctx context.Context, filters []Filter) (T, error) {
+	return c.inner.FindOne(ctx, filters)
+}
+
+func (c *CachedRepo[T, ID]) CountWhere(ctx context.Context, p ListParams) (int64, error) {
+	return c.inner.CountWhere(ctx, p)
+}
+
+func (c *CachedRepo[T, ID]) Exists(ctx context.Context, filters []Filter) (bool, error) {
+	return c.inner.Exists(ctx, filters)
+}
+
+func (c *CachedRepo[T, ID]) Aggregate(ctx context.Context, p AggregateParams) ([]AggregateRow, error) {
+	return c.inner.Aggregate(ctx, p)
+}
+
+func (c *CachedRepo[T, ID]) Facets(ctx context.Context, p ListParams, fields []string) (map[string][]FacetValue, error) {
+	return c.inner.Facets(ctx, p, fields)
+}
+
+func (c *CachedRepo[T, ID]) Iterate(ctx context.Context, p ListParams, batchSize int, fn func(batch []T) error) (int64, error) {
+	return c.inner.Iterate(ctx, p, batchSize, fn)
+}
+
+func (c *CachedRepo[T, ID]) Create(ctx context.Context, in *T) error {
+	if err := c.inner.Create(ctx, in); err != nil {
+		return err
+	}
+	c.invalidate(ctx, nil, false)
+	return nil
+}
+
+func (c *CachedRepo[T, ID]) CreateMany(ctx context.Context, items []T, batchSize int) ([]ID, error) {
+	ids, err := c.inner.CreateMany(ctx, items, batchSize)
+	if err == nil {
+		c.invalidate(ctx, nil, false)
+	}
+	return ids, err
+}
+
+// Upsert может обновить существующую запись с неизвестным заранее ID — сбрасывается всё
+func (c *CachedRepo[T, ID]) Upsert(ctx context.Context, in *T, p UpsertParams) error {
+	if err := c.inner.Upsert(ctx, in, p); err != nil {
+		return err
+	}
+	c.invalidate(ctx, nil, true)
+	return nil
+}
+
+func (c *CachedRepo[T, ID]) UpsertMany(ctx context.Context, items []T, p UpsertParams) ([]ID, error) {
+	ids, err := c.inner.UpsertMany(ctx, items, p)
+	if err == nil {
+		c.invalidate(ctx, nil, true)
+	}
+	return ids, err
+}
+
+func (c *CachedRepo[T, ID]) Update(ctx context.Context, id ID, patch map[string]any) (T, error) {
+	out, err := c.inner.Update(ctx, id, patch)
+	if err == nil {
+		c.invalidate(ctx, []ID{id}, false)
+	}
+	return out, err
+}
+
+func (c *CachedRepo[T, ID]) UpdateMany(ctx context.Context, ids []ID, patch map[string]any) (int64, error) {
+	n, err := c.inner.UpdateMany(ctx, ids, patch)
+	if err == nil {
+		c.invalidate(ctx, ids, false)
+	}
+	return n, err
+}
+
+func (c *CachedRepo[T, ID]) UpdateWhere(ctx context.Context, filters []Filter, patch map[string]any) (int64, error) {
+	n, err := c.inner.UpdateWhere(ctx, filters, patch)
+	if err == nil {
+		c.invalidate(ctx, nil, true)
+	}
+	return n, err
+}
+
+func (c *CachedRepo[T, ID]) Save(ctx context.Context, id ID, obj T) (T, error) {
+	out, err := c.inner.Save(ctx, id, obj)
+	if err == nil {
+		c.invalidate(ctx, []ID{id}, false)
+	}
+	return out, err
+}
+
+func (c *CachedRepo[T, ID]) Delete(ctx context.Context, id ID) error {
+	if err := c.inner.Delete(ctx, id); err != nil {
+		return err
+	}
+	c.invalidate(ctx, []ID{id}, false)
+	return nil
+}
+
+func (c *CachedRepo[T, ID]) DeleteMany(ctx context.Context, ids []ID) (int64, error) {
+	n, err := c.inner.DeleteMany(ctx, ids)
+	if err == nil {
+		c.invalidate(ctx, ids, false)
+	}
+	return n, err
+}
+
+func (c *CachedRepo[T, ID]) DeleteWhere(ctx context.Context, filters []Filter, opts DeleteWhereOptions) (int64, error) {
+	n, err := c.inner.DeleteWhere(ctx, filters, opts)
+	if err == nil {
+		c.invalidate(ctx, nil, true)
+	}
+	return n, err
+}
+
+// WithTx — декоратор над транзакционным репозиторием: чтения идут мимо кэша, записи сбрасывают ключи сразу
+// (commit такой транзакции не отслеживается — для точного сброса используйте RunInTx)
+func (c *CachedRepo[T, ID]) WithTx(tx *gorm.DB) Repo[T, ID] {
+	cp := *c
+	cp.inner = c.inner.WithTx(tx)
+	cp.tx = true
+	return &cp
+}
+
+var _ Repo[struct{}, uint] = (*CachedRepo[struct{}, uint])(nil)
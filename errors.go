@@ -0,0 +1,22 @@
+package axcrud
+
+import "errors"
+
+var (
+	// ErrNotFound — запись не найдена (FindOne); оборачивает gorm.ErrRecordNotFound
+	ErrNotFound = errors.New("record not found")
+	// ErrForbidden — политика (Policy) запрещает операцию над записью
+	ErrForbidden = errors.New("forbidden")
+	// ErrEmptyFilters — массовая операция без фильтров (затронула бы всю таблицу)
+	ErrEmptyFilters = errors.New("empty filters")
+	// ErrNoTenant — у репозитория задан TenantColumn, а в ctx нет WithTenantID
+	ErrNoTenant = errors.New("tenant is not set in context")
+	// ErrTenantChange — попытка изменить колонку тенанта
+	ErrTenantChange = errors.New("changing tenant is not allowed")
+	// ErrMaxAffectedExceeded — операция затронула бы больше строк, чем разрешено; изменения откатываются
+	ErrMaxAffectedExceeded = errors.New("max affected rows exceeded")
+	// ErrQueryTooExpensive — запрос превышает лимиты RepoConfig (MaxFilters, MaxInValues, NoLeadingWildcard и т.п.)
+	ErrQueryTooExpensive = errors.New("query exceeds limits")
+	// ErrVersioningDisabled — история запрошена у репозитория без WithVersioning
+	ErrVersioningDisabled = errors.New("versioning is not enabled")
+)
@@ -0,0 +1,158 @@
+package axcrud
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+)
+
+// Event — изменение записей ресурса. В событии только ID (без данных),
+// чтобы не раздавать поля в обход Policy/FieldAccess: клиент перечитывает их сам.
+// IDs — только затронутые записи: у UpdateMany/DeleteMany/UpdateWhere/DeleteWhere они выбираются
+// тем же запросом (Scopes, тенант, Restrict) перед записью.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Resource string    `json:"resource"`
+	Type     EventType `json:"type"`
+	IDs      []any     `json:"ids,omitempty"`
+	TenantID any       `json:"-"`
+	Time     time.Time `json:"date"`
+}
+
+const (
+	defaultEventBuffer = 1000
+	subscriberBuffer   = 64
+)
+
+// Broker — in-process шина событий с кольцевым буфером для догоняющих подписчиков (Last-Event-ID).
+type Broker struct {
+	mu   sync.Mutex
+	seq  uint64
+	ring []Event
+	head int // индекс самого старого события, когда ring заполнен
+	size int
+	subs map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	ch     chan Event
+	filter func(Event) bool
+}
+
+// NewBroker — bufferSize событий хранится для replay (по умолчанию 1000)
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBuffer
+	}
+	return &Broker{size: bufferSize, subs: map[*subscriber]struct{}{}}
+}
+
+// Publish присваивает событию очередной ID и рассылает подписчикам
+func (b *Broker) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	e.ID = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if len(b.ring) < b.size {
+		b.ring = append(b.ring, e)
+	} else {
+		b.ring[b.head] = e
+		b.head = (b.head + 1) % b.size
+	}
+	for s := range b.subs {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			// не успевает читать — отключаем, клиент переподключится с Last-Event-ID
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+	return e
+}
+
+// Subscribe — сначала события из буфера с ID > lastID, затем новые.
+// Канал закрывается при отмене ctx или если подписчик отстал.
+func (b *Broker) Subscribe(ctx context.Context, lastID uint64, filter func(Event) bool) <-chan Event {
+	b.mu.Lock()
+	var replay []Event
+	if lastID > 0 {
+		for i := range b.ring {
+			e := b.ring[(b.head+i)%len(b.ring)]
+			if e.ID > lastID && (filter == nil || filter(e)) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	s := &subscriber{ch: make(chan Event, len(replay)+subscriberBuffer), filter: filter}
+	for _, e := range replay {
+		s.ch <- e
+	}
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		if _, ok := b.subs[s]; ok {
+			delete(b.subs, s)
+			close(s.ch)
+		}
+		b.mu.Unlock()
+	}()
+	return s.ch
+}
+
+// WithEvents — опция NewGormRepo: изменения публикуются в b под именем resource (после commit, см. AfterCommit)
+func WithEvents[T any, ID IDConstraint](b *Broker, resource string) func(*GormRepo[T, ID]) {
+	return func(r *GormRepo[T, ID]) {
+		r.events = b
+		r.resource = resource
+	}
+}
+
+func (r *GormRepo[T, ID]) tracksChanges() bool {
+	return r.events != nil || r.outbox || r.versions
+}
+
+// recordChange — всё, что сопровождает изменение: версии, outbox, события
+func (r *GormRepo[T, ID]) recordChange(ctx context.Context, typ EventType, ids []ID) error {
+	if r.versions {
+		if err := r.snapshot(ctx, typ, ids); err != nil {
+			return err
+		}
+	}
+	return r.publish(ctx, typ, ids)
+}
+
+// publish — событие в outbox (в текущей транзакции) и в брокер (после commit)
+func (r *GormRepo[T, ID]) publish(ctx context.Context, typ EventType, ids []ID) error {
+	if r.events == nil && !r.outbox {
+		return nil
+	}
+	e := Event{Resource: r.resource, Type: typ, IDs: toAnySlice(ids), Time: time.Now()}
+	e.TenantID, _ = TenantIDFromContext(ctx)
+	if r.outbox {
+		if err := writeOutbox(r.conn(ctx), e); err != nil {
+			return err
+		}
+	}
+	if r.events != nil {
+		AfterCommit(ctx, func() { r.events.Publish(e) })
+	}
+	return nil
+}
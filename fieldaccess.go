@@ -0,0 +1,161 @@
+package axcrud
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// FieldAccess — права на колонку по ролям Principal из ctx.
+// nil — без ограничений; пустой (не nil) список — никому.
+type FieldAccess struct {
+	ReadRoles  []string
+	WriteRoles []string
+}
+
+func (a FieldAccess) CanRead(p Principal) bool {
+	return a.ReadRoles == nil || p.hasAnyRole(a.ReadRoles)
+}
+
+func (a FieldAccess) CanWrite(p Principal) bool {
+	return a.WriteRoles == nil || p.hasAnyRole(a.WriteRoles)
+}
+
+func (p Principal) hasAnyRole(roles []string) bool {
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// column — имя колонки для ключа из запроса (колонка или имя поля структуры)
+func (r *GormRepo[T, ID]) column(key string) string {
+	if r.schema != nil {
+		if f := r.schema.LookUpField(key); f != nil && f.DBName != "" {
+			return f.DBName
+		}
+	}
+	return key
+}
+
+func (r *GormRepo[T, ID]) fieldAccess(key string) (FieldAccess, bool) {
+	if len(r.cfg.FieldAccess) == 0 {
+		return FieldAccess{}, false
+	}
+	a, ok := r.cfg.FieldAccess[r.column(key)]
+	return a, ok
+}
+
+func (r *GormRepo[T, ID]) canReadField(ctx context.Context, key string) bool {
+	a, ok := r.fieldAccess(key)
+	if !ok {
+		return true
+	}
+	p, _ := PrincipalFromContext(ctx)
+	return a.CanRead(p)
+}
+
+// checkReadable — фильтр/сортировка/поиск/агрегация по скрытой колонке запрещены
+func (r *GormRepo[T, ID]) checkReadable(ctx context.Context, key string) error {
+	if !r.canReadField(ctx, key) {
+		return fmt.Errorf("%w: field '%s' is not readable", ErrForbidden, key)
+	}
+	return nil
+}
+
+func (r *GormRepo[T, ID]) checkWritable(ctx context.Context, key string) error {
+	a, ok := r.fieldAccess(key)
+	if !ok {
+		return nil
+	}
+	p, _ := PrincipalFromContext(ctx)
+	if !a.CanWrite(p) {
+		return fmt.Errorf("%w: field '%s' is not writable", ErrForbidden, key)
+	}
+	return nil
+}
+
+// restrictedFields — поля модели, которые текущий Principal не может читать (read=true) или менять
+func (r *GormRepo[T, ID]) restrictedFields(ctx context.Context, read bool) []*schema.Field {
+	if len(r.cfg.FieldAccess) == 0 || r.schema == nil {
+		return nil
+	}
+	p, _ := PrincipalFromContext(ctx)
+	var out []*schema.Field
+	for col, a := range r.cfg.FieldAccess {
+		f := r.schema.LookUpField(col)
+		if f == nil {
+			continue
+		}
+		if (read && !a.CanRead(p)) || (!read && !a.CanWrite(p)) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// maskFields обнуляет в записях колонки, недоступные для чтения. Поле не удаляется: без omitempty
+// клиент увидит нулевое значение, неотличимое от настоящего (salary: 0)
+func (r *GormRepo[T, ID]) maskFields(ctx context.Context, items ...*T) {
+	hidden := r.restrictedFields(ctx, true)
+	if len(hidden) == 0 {
+		return
+	}
+	for _, obj := range items {
+		rv := reflect.ValueOf(obj).Elem()
+		for _, f := range hidden {
+			f.ReflectValueOf(ctx, rv).Set(reflect.Zero(f.FieldType))
+		}
+	}
+}
+
+func (r *GormRepo[T, ID]) maskSlice(ctx context.Context, items []T) {
+	if len(r.cfg.FieldAccess) == 0 {
+		return
+	}
+	for i := range items {
+		r.maskFields(ctx, &items[i])
+	}
+}
+
+// checkWriteObj — при вставке недоступные для записи колонки должны оставаться нулевыми
+func (r *GormRepo[T, ID]) checkWriteObj(ctx context.Context, items ...*T) error {
+	locked := r.restrictedFields(ctx, false)
+	if len(locked) == 0 {
+		return nil
+	}
+	for _, obj := range items {
+		rv := reflect.ValueOf(obj).Elem()
+		for _, f := range locked {
+			if _, zero := f.ValueOf(ctx, rv); !zero {
+				return fmt.Errorf("%w: field '%s' is not writable", ErrForbidden, f.DBName)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *GormRepo[T, ID]) checkWriteSlice(ctx context.Context, items []T) error {
+	if len(r.cfg.FieldAccess) == 0 {
+		return nil
+	}
+	for i := range items {
+		if err := r.checkWriteObj(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveOmits — колонки, которые Save не должен трогать: клиент их не видит или не может менять
+func (r *GormRepo[T, ID]) saveOmits(ctx context.Context) []string {
+	var cols []string
+	for _, f := range append(r.restrictedFields(ctx, true), r.restrictedFields(ctx, false)...) {
+		cols = append(cols, f.DBName)
+	}
+	return cols
+}
@@ -0,0 +1,106 @@
+package axcrud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultMaxPageSize = 1000
+
+// QueryTimeouts — таймауты операций через context.WithTimeout (драйвер отменяет запрос); 0 — без таймаута.
+// Iterate не ограничивается: он работает, пока работает колбэк.
+type QueryTimeouts struct {
+	// GetList, CountWhere, Aggregate, Facets
+	List time.Duration
+	// GetOne, GetMany, GetManyOrdered, FindOne, Exists, Count
+	Get time.Duration
+	// Create, Update, Save, Delete, Upsert и массовые операции
+	Write time.Duration
+}
+
+type queryKind int
+
+const (
+	queryList queryKind = iota
+	queryGet
+	queryWrite
+)
+
+// withTimeout — ctx с таймаутом операции; более ранний дедлайн вызывающего сохраняется
+func (r *GormRepo[T, ID]) withTimeout(ctx context.Context, kind queryKind) (context.Context, context.CancelFunc) {
+	var d time.Duration
+	switch kind {
+	case queryList:
+		d = r.cfg.Timeouts.List
+	case queryGet:
+		d = r.cfg.Timeouts.Get
+	case queryWrite:
+		d = r.cfg.Timeouts.Write
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func (r *GormRepo[T, ID]) maxPageSize() int {
+	if r.cfg.MaxPageSize > 0 {
+		return r.cfg.MaxPageSize
+	}
+	return defaultMaxPageSize
+}
+
+// countFilters — сколько фильтров попадёт в WHERE: с пустым Field applyFilters пропускает
+func countFilters(filters []Filter) int {
+	n := 0
+	for _, f := range filters {
+		if strings.TrimSpace(f.Field) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *GormRepo[T, ID]) checkFilterCount(filters []Filter) error {
+	if r.cfg.MaxFilters <= 0 {
+		return nil
+	}
+	if n := countFilters(filters); n > r.cfg.MaxFilters {
+		return fmt.Errorf("%w: %d filters, max %d", ErrQueryTooExpensive, n, r.cfg.MaxFilters)
+	}
+	return nil
+}
+
+func (r *GormRepo[T, ID]) checkInValues(field string, vals []any) error {
+	if r.cfg.MaxInValues > 0 && len(vals) > r.cfg.MaxInValues {
+		return fmt.Errorf("%w: %d values in '%s', max %d", ErrQueryTooExpensive, len(vals), field, r.cfg.MaxInValues)
+	}
+	return nil
+}
+
+// checkWildcard — для полей из NoLeadingWildcard запрещены contains/icontains/endswith
+// и startswith со значением, которое само начинается с % или _
+func (r *GormRepo[T, ID]) checkWildcard(field, op string, value any) error {
+	if !r.cfg.NoLeadingWildcard.Has(field) {
+		return nil
+	}
+	switch op {
+	case "contains", "icontains", "endswith":
+	case "startswith":
+		if !strings.HasPrefix(fmt.Sprint(value), "%") && !strings.HasPrefix(fmt.Sprint(value), "_") {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: leading wildcard on '%s'", ErrQueryTooExpensive, field)
+}
+
+func (r *GormRepo[T, ID]) checkSearchFields(requested []string) error {
+	if r.cfg.MaxSearchFields > 0 && len(requested) > r.cfg.MaxSearchFields {
+		return fmt.Errorf("%w: %d search fields, max %d", ErrQueryTooExpensive, len(requested), r.cfg.MaxSearchFields)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package axcrud
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"gorm.io/gorm/clause"
+)
+
+var errStopIteration = errors.New("stop iteration")
+
+// Iterate проходит все записи, подходящие под фильтры/поиск p, пачками по batchSize.
+// Обход идёт по PK (keyset: id > last ORDER BY id), поэтому не зависит от лимита PerPage
+// и не пропускает строки при вставках/удалениях между пачками. p.Sort и p.Pagination игнорируются.
+// Записи, запрещённые Policy.CanRead, пропускаются (пачка может быть меньше batchSize).
+// Возвращает количество обработанных записей (в том числе при ошибке/отмене ctx).
+func (r *GormRepo[T, ID]) Iterate(ctx context.Context, p ListParams, batchSize int, fn func(batch []T) error) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	var processed int64
+	var last *ID
+	for {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+		q, err := r.applyWhere(ctx, r.base(ctx), p)
+		if err != nil {
+			return processed, err
+		}
+		if last != nil {
+			q = q.Where(clause.Gt{Column: clause.Column{Name: r.idCol}, Value: *last})
+		}
+		var batch []T
+		err = r.applyPreloads(q).
+			Order(clause.OrderByColumn{Column: clause.Column{Name: r.idCol}}).
+			Limit(batchSize).
+			Find(&batch).Error
+		if err != nil {
+			return processed, err
+		}
+		if len(batch) == 0 {
+			return processed, nil
+		}
+		// курсор — по последней выбранной строке, даже если CanRead её отсеет
+		id, err := r.idOf(ctx, &batch[len(batch)-1])
+		if err != nil {
+			return processed, err
+		}
+		full := len(batch) == batchSize
+		if batch = r.readable(ctx, batch); len(batch) > 0 {
+			r.maskSlice(ctx, batch)
+			if err = fn(batch); err != nil {
+				return processed, err
+			}
+			processed += int64(len(batch))
+		}
+		if !full {
+			return processed, nil
+		}
+		last = &id
+	}
+}
+
+// All — то же, что Iterate, в виде range-over-func:
+//
+//	for u, err := range repo.All(ctx, params, 500) {
+//		if err != nil { ... }
+//	}
+func (r *GormRepo[T, ID]) All(ctx context.Context, p ListParams, batchSize int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		_, err := r.Iterate(ctx, p, batchSize, func(batch []T) error {
+			for _, item := range batch {
+				if !yield(item, nil) {
+					return errStopIteration
+				}
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package axcrud
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// InstrumentationName — имя tracer/meter по умолчанию
+const InstrumentationName = "github.com/axgrid/axcrud"
+
+// Классы ошибок для метрик и логов (ErrorClass)
+const (
+	ErrClassNotFound  = "not_found"
+	ErrClassForbidden = "forbidden"
+	ErrClassTenant    = "tenant"
+	ErrClassInvalid   = "invalid"
+	ErrClassConflict  = "conflict"
+	ErrClassTimeout   = "timeout"
+	ErrClassCanceled  = "canceled"
+	ErrClassInternal  = "internal"
+)
+
+// ErrorClass — класс ошибки по типизированным ошибкам пакета и GORM; "" для nil
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound), errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrVersioningDisabled):
+		return ErrClassNotFound
+	case errors.Is(err, ErrForbidden):
+		return ErrClassForbidden
+	case errors.Is(err, ErrNoTenant), errors.Is(err, ErrTenantChange):
+		return ErrClassTenant
+	case errors.Is(err, ErrEmptyFilters), errors.Is(err, ErrMaxAffectedExceeded), errors.Is(err, ErrQueryTooExpensive):
+		return ErrClassInvalid
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated):
+		return ErrClassConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrClassCanceled
+	}
+	return ErrClassInternal
+}
+
+// ObserveOptions — настройки ObservedRepo (и middleware webcrud). Всё опционально:
+// без Tracer/Meter берутся глобальные провайдеры otel (noop, пока приложение их не настроило), без Logger — не логируем.
+type ObserveOptions struct {
+	Tracer trace.Tracer
+	Meter  metric.Meter
+	Logger *slog.Logger
+	// Запросы дольше порога логируются с уровнем Warn; 0 — не выделять
+	SlowThreshold time.Duration
+}
+
+func (o ObserveOptions) withDefaults() ObserveOptions {
+	if o.Tracer == nil {
+		o.Tracer = otel.Tracer(InstrumentationName)
+	}
+	if o.Meter == nil {
+		o.Meter = otel.Meter(InstrumentationName)
+	}
+	return o
+}
+
+// Instruments — метрики операции: длительность, ошибки по классам, число строк
+type Instruments struct {
+	Duration metric.Float64Histogram
+	Errors   metric.Int64Counter
+	Rows     metric.Int64Histogram
+}
+
+// NewInstruments создаёт метрики с префиксом prefix (например, "axcrud.repo")
+func NewInstruments(m metric.Meter, prefix string) (Instruments, error) {
+	var in Instruments
+	var err error
+	if in.Duration, err = m.Float64Histogram(prefix+".duration", metric.WithUnit("s"),
+		metric.WithDescription("Operation latency")); err != nil {
+		return in, err
+	}
+	if in.Errors, err = m.Int64Counter(prefix+".errors",
+		metric.WithDescription("Failed operations by error class")); err != nil {
+		return in, err
+	}
+	in.Rows, err = m.Int64Histogram(prefix+".rows", metric.WithDescription("Rows returned or affected"))
+	return in, err
+}
+
+// ObservedRepo — Repo с трейсами, метриками и логами на каждую операцию.
+// Атрибуты: axcrud.resource, axcrud.operation, error.class; ListParams — без значений фильтров и строки поиска.
+type ObservedRepo[T any, ID IDConstraint] struct {
+	inner    Repo[T, ID]
+	resource string
+	opts     ObserveOptions
+	in       Instruments
+}
+
+func NewObservedRepo[T any, ID IDConstraint](inner Repo[T, ID], resource string, opts ObserveOptions) (*ObservedRepo[T, ID], error) {
+	opts = opts.withDefaults()
+	in, err := NewInstruments(opts.Meter, "axcrud.repo")
+	if err != nil {
+		return nil, err
+	}
+	return &ObservedRepo[T, ID]{inner: inner, resource: resource, opts: opts, in: in}, nil
+}
+
+// observe — общая обёртка: span, метрики, лог. fn возвращает число строк (-1 — не считать).
+func (o *ObservedRepo[T, ID]) observe(ctx context.Context, op string, attrs []attribute.KeyValue, fn func(ctx context.Context) (int64, error)) error {
+	base := []attribute.KeyValue{
+		attribute.String("axcrud.resource", o.resource),
+		attribute.String("axcrud.operation", op),
+	}
+	ctx, span := o.opts.Tracer.Start(ctx, o.resource+"."+op,
+		trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(append(base, attrs...)...))
+	defer span.End()
+
+	start := time.Now()
+	rows, err := fn(ctx)
+	elapsed := time.Since(start)
+
+	class := ErrorClass(err)
+	mattrs := base
+	if class != "" {
+		mattrs = append(mattrs, attribute.String("error.class", class))
+		o.in.Errors.Add(ctx, 1, metric.WithAttributes(mattrs...))
+		span.SetAttributes(attribute.String("error.class", class))
+		if class == ErrClassInternal || class == ErrClassTimeout {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	o.in.Duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(mattrs...))
+	if rows >= 0 && err == nil {
+		o.in.Rows.Record(ctx, rows, metric.WithAttributes(base...))
+		span.SetAttributes(attribute.Int64("axcrud.rows", rows))
+	}
+	logOperation(ctx, o.opts, "axcrud repo", elapsed, err, class,
+		slog.String("resource", o.resource), slog.String("op", op), slog.Int64("rows", rows))
+	return err
+}
+
+// logOperation — Error для внутренних ошибок, Warn для остальных ошибок и медленных операций, иначе Debug
+func logOperation(ctx context.Context, opts ObserveOptions, msg string, elapsed time.Duration, err error, class string, attrs ...slog.Attr) {
+	if opts.Logger == nil {
+		return
+	}
+	level := slog.LevelDebug
+	attrs = append(attrs, slog.Duration("duration", elapsed))
+	if err != nil {
+		level = slog.LevelWarn
+		if class == ErrClassInternal {
+			level = slog.LevelError
+		}
+		attrs = append(attrs, slog.String("error.class", class), slog.String("error", err.Error()))
+	} else if opts.SlowThreshold > 0 && elapsed >= opts.SlowThreshold {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	opts.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ListParamsAttributes — безопасное описание ListParams для span: поля и операторы фильтров, сортировка, пагинация.
+// Значения фильтров и строка поиска не попадают (могут содержать персональные данные).
+func ListParamsAttributes(p ListParams) []attribute.KeyValue {
+	filters := make([]string, 0, len(p.Filters))
+	for _, f := range p.Filters {
+		filters = append(filters, strings.TrimSpace(f.Field)+":"+strings.ToLower(strings.TrimSpace(f.Operator)))
+	}
+	attrs := []attribute.KeyValue{
+		attribute.StringSlice("axcrud.filters", filters),
+		attribute.Bool("axcrud.search", strings.TrimSpace(p.Search) != ""),
+		attribute.Int("axcrud.page", p.Pagination.Page),
+		attribute.Int("axcrud.per_page", p.Pagination.PerPage),
+	}
+	if len(p.SearchFields) > 0 {
+		attrs = append(attrs, attribute.StringSlice("axcrud.search_fields", p.SearchFields))
+	}
+	if p.Sort != nil {
+		attrs = append(attrs, attribute.String("axcrud.sort", p.Sort.Field+" "+strings.ToLower(p.Sort.Order)))
+	}
+	return attrs
+}
+
+func filterAttributes(filters []Filter) []attribute.KeyValue {
+	return ListParamsAttributes(ListParams{Filters: filters})[:1]
+}
+
+func (o *ObservedRepo[T, ID]) GetList(ctx context.Context, p ListParams) (items []T, total int64, err error) {
+	err = o.observe(ctx, "GetList", ListParamsAttributes(p), func(ctx context.Context) (int64, error) {
+		items, total, err = o.inner.GetList(ctx, p)
+		return int64(len(items)), err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) GetOne(ctx context.Context, id ID) (out T, err error) {
+	err = o.observe(ctx, "GetOne", nil, func(ctx context.Context) (int64, error) {
+		out, err = o.inner.GetOne(ctx, id)
+		return -1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) FindOne(ctx context.Context, filters []Filter) (out T, err error) {
+	err = o.observe(ctx, "FindOne", filterAttributes(filters), func(ctx context.Context) (int64, error) {
+		out, err = o.inner.FindOne(ctx, filters)
+		return -1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) CountWhere(ctx context.Context, p ListParams) (n int64, err error) {
+	err = o.observe(ctx, "CountWhere", ListParamsAttributes(p), func(ctx context.Context) (int64, error) {
+		n, err = o.inner.CountWhere(ctx, p)
+		return -1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Exists(ctx context.Context, filters []Filter) (ok bool, err error) {
+	err = o.observe(ctx, "Exists", filterAttributes(filters), func(ctx context.Context) (int64, error) {
+		ok, err = o.inner.Exists(ctx, filters)
+		return -1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Create(ctx context.Context, in *T) error {
+	return o.observe(ctx, "Create", nil, func(ctx context.Context) (int64, error) {
+		return 1, o.inner.Create(ctx, in)
+	})
+}
+
+func (o *ObservedRepo[T, ID]) CreateMany(ctx context.Context, items []T, batchSize int) (ids []ID, err error) {
+	err = o.observe(ctx, "CreateMany", nil, func(ctx context.Context) (int64, error) {
+		ids, err = o.inner.CreateMany(ctx, items, batchSize)
+		return int64(len(ids)), err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Upsert(ctx context.Context, in *T, p UpsertParams) error {
+	return o.observe(ctx, "Upsert", nil, func(ctx context.Context) (int64, error) {
+		return 1, o.inner.Upsert(ctx, in, p)
+	})
+}
+
+func (o *ObservedRepo[T, ID]) UpsertMany(ctx context.Context, items []T, p UpsertParams) (ids []ID, err error) {
+	err = o.observe(ctx, "UpsertMany", nil, func(ctx context.Context) (int64, error) {
+		ids, err = o.inner.UpsertMany(ctx, items, p)
+		return int64(len(ids)), err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Update(ctx context.Context, id ID, patch map[string]any) (out T, err error) {
+	err = o.observe(ctx, "Update", nil, func(ctx context.Context) (int64, error) {
+		out, err = o.inner.Update(ctx, id, patch)
+		return 1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) UpdateMany(ctx context.Context, ids []ID, patch map[string]any) (n int64, err error) {
+	err = o.observe(ctx, "UpdateMany", nil, func(ctx context.Context) (int64, error) {
+		n, err = o.inner.UpdateMany(ctx, ids, patch)
+		return n, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) UpdateWhere(ctx context.Context, filters []Filter, patch map[string]any) (n int64, err error) {
+	err = o.observe(ctx, "UpdateWhere", filterAttributes(filters), func(ctx context.Context) (int64, error) {
+		n, err = o.inner.UpdateWhere(ctx, filters, patch)
+		return n, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Delete(ctx context.Context, id ID) error {
+	return o.observe(ctx, "Delete", nil, func(ctx context.Context) (int64, error) {
+		return 1, o.inner.Delete(ctx, id)
+	})
+}
+
+func (o *ObservedRepo[T, ID]) DeleteMany(ctx context.Context, ids []ID) (n int64, err error) {
+	err = o.observe(ctx, "DeleteMany", nil, func(ctx context.Context) (int64, error) {
+		n, err = o.inner.DeleteMany(ctx, ids)
+		return n, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) DeleteWhere(ctx context.Context, filters []Filter, opts DeleteWhereOptions) (n int64, err error) {
+	err = o.observe(ctx, "DeleteWhere", filterAttributes(filters), func(ctx context.Context) (int64, error) {
+		n, err = o.inner.DeleteWhere(ctx, filters, opts)
+		return n, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Save(ctx context.Context, id ID, obj T) (out T, err error) {
+	err = o.observe(ctx, "Save", nil, func(ctx context.Context) (int64, error) {
+		out, err = o.inner.Save(ctx, id, obj)
+		return 1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Aggregate(ctx context.Context, p AggregateParams) (rows []AggregateRow, err error) {
+	err = o.observe(ctx, "Aggregate", ListParamsAttributes(p.ListParams), func(ctx context.Context) (int64, error) {
+		rows, err = o.inner.Aggregate(ctx, p)
+		return int64(len(rows)), err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Facets(ctx context.Context, p ListParams, fields []string) (out map[string][]FacetValue, err error) {
+	attrs := append(ListParamsAttributes(p), attribute.StringSlice("axcrud.facets", fields))
+	err = o.observe(ctx, "Facets", attrs, func(ctx context.Context) (int64, error) {
+		out, err = o.inner.Facets(ctx, p, fields)
+		return -1, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) Iterate(ctx context.Context, p ListParams, batchSize int, fn func(batch []T) error) (n int64, err error) {
+	err = o.observe(ctx, "Iterate", ListParamsAttributes(p), func(ctx context.Context) (int64, error) {
+		n, err = o.inner.Iterate(ctx, p, batchSize, fn)
+		return n, err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) GetMany(ctx context.Context, ids []ID) (items []T, err error) {
+	err = o.observe(ctx, "GetMany", nil, func(ctx context.Context) (int64, error) {
+		items, err = o.inner.GetMany(ctx, ids)
+		return int64(len(items)), err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) GetManyOrdered(ctx context.Context, ids []ID) (res ManyResult[T, ID], err error) {
+	err = o.observe(ctx, "GetManyOrdered", nil, func(ctx context.Context) (int64, error) {
+		res, err = o.inner.GetManyOrdered(ctx, ids)
+		return int64(len(res.Items)), err
+	})
+	return
+}
+
+func (o *ObservedRepo[T, ID]) WithTx(tx *gorm.DB) Repo[T, ID] {
+	cp := *o
+	cp.inner = o.inner.WithTx(tx)
+	return &cp
+}
+
+var _ Repo[struct{}, uint] = (*ObservedRepo[struct{}, uint])(nil)
@@ -0,0 +1,250 @@
+package axcrud
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxDone    = "done"
+	OutboxDead    = "dead" // исчерпаны попытки доставки
+)
+
+// OutboxMessage — строка таблицы axcrud_outbox: событие, записанное в той же транзакции, что и изменение
+type OutboxMessage struct {
+	ID             uint64          `json:"id" gorm:"primaryKey"`
+	IdempotencyKey string          `json:"idempotencyKey" gorm:"size:64;uniqueIndex"`
+	Resource       string          `json:"resource" gorm:"size:128;index"`
+	Type           EventType       `json:"type" gorm:"size:32"`
+	IDs            json.RawMessage `json:"ids,omitempty" gorm:"column:ids"`
+	TenantID       string          `json:"tenantId,omitempty" gorm:"size:128"`
+	Status         string          `json:"-" gorm:"size:16;index:idx_axcrud_outbox_poll,priority:1"`
+	NextAttemptAt  time.Time       `json:"-" gorm:"index:idx_axcrud_outbox_poll,priority:2"`
+	Attempts       int             `json:"-"`
+	LockedUntil    *time.Time      `json:"-"`
+	LockedBy       string          `json:"-" gorm:"size:64"`
+	LastError      string          `json:"-"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	PublishedAt    *time.Time      `json:"-"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "axcrud_outbox"
+}
+
+// MigrateOutbox создаёт/обновляет таблицу axcrud_outbox
+func MigrateOutbox(db *gorm.DB) error {
+	return db.AutoMigrate(&OutboxMessage{})
+}
+
+// WithOutbox — опция NewGormRepo: каждое изменение пишет строку в axcrud_outbox в той же транзакции.
+// Если ctx без транзакции, операция сама выполняется в транзакции. Таблица — MigrateOutbox.
+func WithOutbox[T any, ID IDConstraint](resource string) func(*GormRepo[T, ID]) {
+	return func(r *GormRepo[T, ID]) {
+		r.outbox = true
+		r.resource = resource
+	}
+}
+
+func writeOutbox(db *gorm.DB, e Event) error {
+	ids, err := json.Marshal(e.IDs)
+	if err != nil {
+		return err
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	msg := OutboxMessage{
+		IdempotencyKey: key,
+		Resource:       e.Resource,
+		Type:           e.Type,
+		IDs:            ids,
+		Status:         OutboxPending,
+		NextAttemptAt:  e.Time,
+		CreatedAt:      e.Time,
+	}
+	if e.TenantID != nil {
+		msg.TenantID = fmt.Sprint(e.TenantID)
+	}
+	return db.Create(&msg).Error
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// writeTx — fn в транзакции, если репозиторий пишет outbox или версии либо проверяет Policy
+// (проверка и изменение должны видеть одни и те же строки), а в ctx транзакции ещё нет
+func (r *GormRepo[T, ID]) writeTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := r.withTimeout(ctx, queryWrite)
+	defer cancel()
+	if !r.outbox && !r.versions && r.policy == nil {
+		return fn(ctx)
+	}
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	return NewTxManager(r.db).RunInTx(ctx, fn)
+}
+
+func inWriteTx[V any, T any, ID IDConstraint](ctx context.Context, r *GormRepo[T, ID], fn func(ctx context.Context) (V, error)) (V, error) {
+	var out V
+	err := r.writeTx(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = fn(ctx)
+		return err
+	})
+	return out, err
+}
+
+// ---------- Доставка ----------
+
+// Publisher доставляет сообщения outbox. Доставка "at least once": при сбое после успешной отправки
+// сообщение уйдёт повторно с тем же IdempotencyKey — получатель должен по нему дедуплицировать.
+type Publisher interface {
+	Publish(ctx context.Context, msg OutboxMessage) error
+}
+
+// MemoryPublisher — Publisher в память (тесты, in-process обработчики); повторы по IdempotencyKey отбрасывает
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	seen     map[string]struct{}
+	messages []OutboxMessage
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{seen: map[string]struct{}{}}
+}
+
+func (p *MemoryPublisher) Publish(_ context.Context, msg OutboxMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.seen[msg.IdempotencyKey]; ok {
+		return nil
+	}
+	p.seen[msg.IdempotencyKey] = struct{}{}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func (p *MemoryPublisher) Messages() []OutboxMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]OutboxMessage(nil), p.messages...)
+}
+
+// WebhookPublisher — POST сообщения JSON-ом на URL; ключ идемпотентности — в заголовке Idempotency-Key.
+// Любой ответ, кроме 2xx, — ошибка (будет повтор).
+type WebhookPublisher struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client // nil — клиент с таймаутом 10s
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, msg OutboxMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", msg.IdempotencyKey)
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type RelayOptions struct {
+	BatchSize    int           // сообщений за проход, по умолчанию 100
+	PollInterval time.Duration // пауза, когда outbox пуст; по умолчанию 1s
+	LockTimeout  time.Duration // через сколько захват упавшего воркера снимается; по умолчанию 30s
+	MaxAttempts  int           // после стольких неудач — OutboxDead; по умолчанию 10
+	BaseBackoff  time.Duration // первая пауза перед повтором, дальше x2; по умолчанию 1s
+	MaxBackoff   time.Duration // по умолчанию 5m
+	WorkerID     string        // имя воркера в locked_by; по умолчанию случайное
+	Logger       *slog.Logger  // куда Run пишет ошибки прохода (БД недоступна и т.п.); по умолчанию slog.Default()
+}
+
+// Relay — воркер, доставляющий outbox через Publisher. Можно запускать несколько экземпляров:
+// сообщение захватывается условным UPDATE, поэтому его обрабатывает только один воркер.
+// Порядок доставки — по ID, но сообщение на повторе может обогнать следующие.
+type Relay struct {
+	db  *gorm.DB
+	pub Publisher
+	q   workQueue
+}
+
+func NewRelay(db *gorm.DB, pub Publisher, opts RelayOptions) *Relay {
+	return &Relay{db: db, pub: pub, q: newWorkQueue(opts)}
+}
+
+// Run обрабатывает outbox до отмены ctx
+func (r *Relay) Run(ctx context.Context) error {
+	return r.q.run(ctx, r.ProcessBatch)
+}
+
+// ProcessBatch — один проход: захватить до BatchSize готовых сообщений и доставить их.
+// Возвращает число обработанных (доставленных или отложенных) сообщений.
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	db := r.db.WithContext(ctx)
+	now := time.Now()
+	ids, err := r.q.pick(db, &OutboxMessage{}, now)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, id := range ids {
+		claimed, err := r.q.claim(db, &OutboxMessage{}, id, now)
+		if err != nil {
+			return processed, err
+		}
+		if !claimed {
+			continue // забрал другой воркер
+		}
+		var msg OutboxMessage
+		if err = db.First(&msg, id).Error; err != nil {
+			return processed, err
+		}
+		pubErr := r.pub.Publish(ctx, msg)
+		if err = r.q.finish(db, &OutboxMessage{}, id, msg.Attempts, pubErr, "published_at", nil); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
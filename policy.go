@@ -0,0 +1,173 @@
+package axcrud
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operation — вид операции, для которой политика сужает запрос
+type Operation string
+
+const (
+	OpRead   Operation = "read"
+	OpCreate Operation = "create"
+	OpUpdate Operation = "update"
+	OpDelete Operation = "delete"
+)
+
+// Policy — построчные права поверх Scopes.
+// Restrict сужает запрос для операции (для OpCreate WHERE не применяется, но ошибка запрещает вставку),
+// Can* проверяют конкретную запись. Отказ — ErrForbidden, а не "не найдено".
+//
+// CanRead проверяется на каждой возвращаемой записи (GetOne, GetMany, FindOne, GetList, Iterate),
+// но запрещённые им записи в GetList просто пропускаются. Всё, что считается в БД — total в GetList,
+// CountWhere, Exists, Aggregate, Facets, — ограничивается только Restrict(OpRead): чтобы счётчики
+// не выдавали скрытые записи, выражайте правило чтения через Restrict.
+type Policy[T any] interface {
+	Restrict(ctx context.Context, op Operation, db *gorm.DB) (*gorm.DB, error)
+	CanRead(ctx context.Context, obj T) bool
+	CanUpdate(ctx context.Context, obj T) bool
+	CanDelete(ctx context.Context, obj T) bool
+}
+
+// AllowAll — политика "всё разрешено"; удобно встраивать и переопределять нужные методы
+type AllowAll[T any] struct{}
+
+func (AllowAll[T]) Restrict(_ context.Context, _ Operation, db *gorm.DB) (*gorm.DB, error) {
+	return db, nil
+}
+func (AllowAll[T]) CanRead(context.Context, T) bool   { return true }
+func (AllowAll[T]) CanUpdate(context.Context, T) bool { return true }
+func (AllowAll[T]) CanDelete(context.Context, T) bool { return true }
+
+// WithPolicy — опция NewGormRepo
+func WithPolicy[T any, ID IDConstraint](p Policy[T]) func(*GormRepo[T, ID]) {
+	return func(r *GormRepo[T, ID]) {
+		r.policy = p
+	}
+}
+
+// restrict — Policy.Restrict для операции; ошибка кладётся в *gorm.DB
+func (r *GormRepo[T, ID]) restrict(ctx context.Context, op Operation, q *gorm.DB) *gorm.DB {
+	if r.policy == nil || q.Error != nil {
+		return q
+	}
+	nq, err := r.policy.Restrict(ctx, op, q)
+	if err != nil {
+		_ = q.AddError(err)
+		return q
+	}
+	return nq
+}
+
+// can — проверка записи политикой для операции
+func (r *GormRepo[T, ID]) can(ctx context.Context, op Operation, obj T) bool {
+	if r.policy == nil {
+		return true
+	}
+	switch op {
+	case OpRead:
+		return r.policy.CanRead(ctx, obj)
+	case OpUpdate:
+		return r.policy.CanUpdate(ctx, obj)
+	case OpDelete:
+		return r.policy.CanDelete(ctx, obj)
+	}
+	return true
+}
+
+// authorize загружает запись (с правом чтения) и проверяет право на op.
+// Запись не маскируется: скрытые FieldAccess поля тоже участвуют в решении.
+// Без политики ничего не делает.
+func (r *GormRepo[T, ID]) authorize(ctx context.Context, op Operation, id ID) error {
+	if r.policy == nil {
+		return nil
+	}
+	obj, err := r.getOne(ReadPrimary(ctx), id)
+	if err != nil {
+		return err
+	}
+	if !r.can(ctx, op, obj) {
+		return fmt.Errorf("%w: %s %v", ErrForbidden, op, id)
+	}
+	return nil
+}
+
+// authorizeMany — authorize для списка ID; записи вне видимости пропускаются (их не затронет и запрос)
+func (r *GormRepo[T, ID]) authorizeMany(ctx context.Context, op Operation, ids []ID) error {
+	if r.policy == nil {
+		return nil
+	}
+	items, err := r.getMany(ReadPrimary(ctx), ids)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		if !r.can(ctx, op, items[i]) {
+			id, err := r.idOf(ctx, &items[i])
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s %v", ErrForbidden, op, id)
+		}
+	}
+	return nil
+}
+
+// authorizeRows — Can* для каждой строки под запросом q (UpdateWhere/DeleteWhere) до самого изменения
+func (r *GormRepo[T, ID]) authorizeRows(ctx context.Context, op Operation, q *gorm.DB) error {
+	if r.policy == nil {
+		return nil
+	}
+	var batch []T
+	return q.Session(&gorm.Session{}).FindInBatches(&batch, defaultIDChunkSize, func(_ *gorm.DB, _ int) error {
+		for i := range batch {
+			if !r.can(ctx, op, batch[i]) {
+				id, err := r.idOf(ctx, &batch[i])
+				if err != nil {
+					return err
+				}
+				return fmt.Errorf("%w: %s %v", ErrForbidden, op, id)
+			}
+		}
+		return nil
+	}).Error
+}
+
+// readable — записи, разрешённые CanRead (срез переиспользуется)
+func (r *GormRepo[T, ID]) readable(ctx context.Context, items []T) []T {
+	if r.policy == nil {
+		return items
+	}
+	out := items[:0]
+	for _, item := range items {
+		if r.can(ctx, OpRead, item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// checkAffected — запись видна и разрешена, но Restrict отсёк её при изменении.
+// 0 строк ещё не отказ: MySQL не считает строки, значения которых не изменились, —
+// поэтому запрет решает повторная проверка видимости записи под Restrict(op).
+func (r *GormRepo[T, ID]) checkAffected(ctx context.Context, op Operation, id ID, tx *gorm.DB) error {
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if r.policy == nil || tx.RowsAffected > 0 {
+		return nil
+	}
+	var n int64
+	err := r.baseFor(ctx, op).Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).Count(&n).Error
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s %v", ErrForbidden, op, id)
+	}
+	return nil
+}
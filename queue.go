@@ -0,0 +1,125 @@
+package axcrud
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// workQueue — общая часть outbox и доставки вебхуков: таблица с колонками
+// status, next_attempt_at, attempts, locked_until, locked_by, last_error.
+type workQueue struct {
+	opts RelayOptions
+}
+
+func newWorkQueue(opts RelayOptions) workQueue {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 30 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.WorkerID == "" {
+		opts.WorkerID, _ = newIdempotencyKey()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return workQueue{opts: opts}
+}
+
+// ready — pending, время повтора наступило, не захвачено (или захват протух)
+func (q workQueue) ready(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("status = ? AND next_attempt_at <= ?", OutboxPending, now).
+		Where("locked_until IS NULL OR locked_until < ?", now)
+}
+
+// pick — ID готовых строк по порядку, не больше BatchSize
+func (q workQueue) pick(db *gorm.DB, model any, now time.Time) ([]uint64, error) {
+	var ids []uint64
+	err := q.ready(db, now).Model(model).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}}).
+		Limit(q.opts.BatchSize).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// claim — условный UPDATE: строку получает только один воркер
+func (q workQueue) claim(db *gorm.DB, model any, id uint64, now time.Time) (bool, error) {
+	res := q.ready(db, now).Model(model).
+		Where("id = ?", id).
+		Updates(map[string]any{"locked_until": now.Add(q.opts.LockTimeout), "locked_by": q.opts.WorkerID})
+	return res.RowsAffected == 1, res.Error
+}
+
+// finish снимает захват и записывает итог попытки; doneAt — колонка времени успешной доставки.
+// Обновление — только если захват ещё наш: иначе строку уже перехватил другой воркер после LockTimeout.
+func (q workQueue) finish(db *gorm.DB, model any, id uint64, attempts int, err error, doneAt string, extra map[string]any) error {
+	now := time.Now()
+	upd := map[string]any{"locked_until": nil, "locked_by": ""}
+	for k, v := range extra {
+		upd[k] = v
+	}
+	if err == nil {
+		upd["status"] = OutboxDone
+		upd[doneAt] = now
+		upd["last_error"] = ""
+	} else {
+		attempts++
+		upd["attempts"] = attempts
+		upd["last_error"] = err.Error()
+		if attempts >= q.opts.MaxAttempts {
+			upd["status"] = OutboxDead
+		} else {
+			upd["next_attempt_at"] = now.Add(q.backoff(attempts))
+		}
+	}
+	return db.Model(model).
+		Where("id = ? AND locked_by = ?", id, q.opts.WorkerID).
+		Updates(upd).Error
+}
+
+func (q workQueue) backoff(attempts int) time.Duration {
+	d := q.opts.BaseBackoff
+	for i := 1; i < attempts && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.opts.MaxBackoff)
+}
+
+// run вызывает batch до отмены ctx, делая паузу PollInterval, когда работы нет.
+// Ошибка прохода не останавливает воркер: она уходит в Logger, следующий проход — после паузы.
+func (q workQueue) run(ctx context.Context, batch func(ctx context.Context) (int, error)) error {
+	for {
+		n, err := batch(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			q.opts.Logger.LogAttrs(ctx, slog.LevelError, "axcrud queue batch failed",
+				slog.String("worker", q.opts.WorkerID), slog.Int("processed", n), slog.Any("error", err))
+		}
+		if err != nil || n == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(q.opts.PollInterval):
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package axcrud
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type readPrimaryKey struct{}
+
+// WithReader — опция NewGormRepo: GetList, GetOne, GetMany(Ordered), CountWhere и Count читают из реплики.
+// Внутри транзакции (ctx с TxFromContext или WithTx) и с ReadPrimary чтение идёт из основной БД.
+func WithReader[T any, ID IDConstraint](reader *gorm.DB) func(*GormRepo[T, ID]) {
+	return func(r *GormRepo[T, ID]) {
+		r.reader = reader
+	}
+}
+
+// ReadPrimary — «read your writes»: чтения с этим ctx идут в основную БД, минуя реплику.
+// Нужен после записи, если реплика может отставать (например, на время запроса или сессии пользователя).
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+func readsPrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(readPrimaryKey{}).(bool)
+	return v
+}
+
+// readConn — реплика, если она задана и не требуется основная БД
+func (r *GormRepo[T, ID]) readConn(ctx context.Context) *gorm.DB {
+	if _, ok := TxFromContext(ctx); ok || r.reader == nil || readsPrimary(ctx) {
+		return r.conn(ctx)
+	}
+	return r.reader.WithContext(ctx)
+}
+
+// readBase — base поверх readConn
+func (r *GormRepo[T, ID]) readBase(ctx context.Context) *gorm.DB {
+	return r.restrict(ctx, OpRead, r.scoped(ctx, r.readConn(ctx)))
+}
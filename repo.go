@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type FieldSet map[string]struct{}
@@ -31,22 +34,63 @@ type RepoConfig struct {
 	AllowedFilterOps map[string]FieldSet
 	// Поля, по которым можно сортировать
 	AllowedSortFields FieldSet
-	// Поля, по которым можно искать (LIKE/ILIKE)
+	// Поля, по которым можно искать (ListParams.Search)
 	AllowedSearchFields FieldSet
+	// Реализация поиска: LikeSearcher (по умолчанию), PostgresSearcher, SQLiteFTS5Searcher, MySQLSearcher
+	Searcher Searcher
+	// Колонки для sum/avg/min/max в Aggregate
+	AllowedAggregateFields FieldSet
+	// Поля для group-by и date-бакетов в Aggregate
+	AllowedGroupFields FieldSet
+	// Поля, для которых можно запрашивать Facets, и лимит значений на поле (по умолчанию 100)
+	AllowedFacetFields FieldSet
+	MaxFacetValues     int
+	// Максимум ID в одном "IN ?" для GetMany; больше — несколько запросов (по умолчанию 500)
+	IDChunkSize int
+	// Поля, которые можно менять через Update/UpdateMany/UpdateWhere; nil — без ограничений
+	WritableFields FieldSet
+	// Права на колонки по ролям Principal: скрытые обнуляются в ответах (поле остаётся в JSON —
+	// помечайте такие поля `json:",omitempty"`), фильтр/сортировка/поиск по ним и запись в них — ErrForbidden
+	FieldAccess map[string]FieldAccess
 	// Прелоады по умолчанию (если нужно)
 	Preloads []string
 	// Скоуп для мulti-tenant/ACL, например: func(db) db.Where("user_id = ?", uid)
 	Scopes []func(*gorm.DB) *gorm.DB
+	// Скоупы с доступом к ctx; ошибка прерывает операцию
+	ContextScopes []ContextScope
+	// Колонка тенанта (например, "user_id"): чтение/изменение только в пределах TenantIDFromContext,
+	// Create проставляет её сам, менять её через Update нельзя. Без тенанта в ctx — ErrNoTenant.
+	TenantColumn string
 	// Мягкое удаление: true по умолчанию; UnscopedDelete удаляет физически
 	UnscopedDelete bool
+	// Upsert по умолчанию: колонки уникального ключа и колонки для обновления при конфликте
+	UpsertConflictColumns []string
+	UpsertUpdateColumns   []string
+	// Лимиты на «дорогие» запросы (0 — без ограничения); превышение — ErrQueryTooExpensive
+	MaxFilters      int
+	MaxSearchFields int // число SearchFields в запросе
+	MaxInValues     int // значений в in/nin
+	// Поля без ведущего % в LIKE (индекс по ним иначе не работает): contains/icontains/endswith запрещены,
+	// поиск по ним — только по префиксу
+	NoLeadingWildcard FieldSet
+	// Максимальный perPage в GetList (по умолчанию 1000); больше — урезается
+	MaxPageSize int
+	Timeouts    QueryTimeouts
 }
 
 type GormRepo[T any, ID IDConstraint] struct {
-	db    *gorm.DB
-	cfg   RepoConfig
-	zero  T // zero value для &zero
-	idCol string
-	table string
+	db       *gorm.DB
+	reader   *gorm.DB // реплика для чтений (WithReader); nil — всё через db
+	cfg      RepoConfig
+	zero     T // zero value для &zero
+	idCol    string
+	table    string
+	schema   *schema.Schema // схема модели (nil, если GORM не смог её разобрать)
+	policy   Policy[T]      // построчные права (WithPolicy); nil — без проверок
+	events   *Broker        // шина изменений (WithEvents)
+	outbox   bool           // писать события в axcrud_outbox (WithOutbox)
+	versions bool           // снимки строк в axcrud_versions (WithVersioning)
+	resource string
 }
 
 type TableNamer interface {
@@ -63,6 +107,11 @@ func NewGormRepo[T any, ID IDConstraint](db *gorm.DB, cfg RepoConfig, opts ...fu
 	if namer, ok := tmp.(TableNamer); ok {
 		r.table = namer.TableName()
 	}
+	// схема нужна, чтобы доставать PK из записей (CreateMany, Upsert и т.п.)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err == nil {
+		r.schema = stmt.Schema
+	}
 	for _, o := range opts {
 		// generic трюк: обернём в приведение типа при вызове
 		o(any(r).(*GormRepo[T, ID]))
@@ -73,111 +122,368 @@ func NewGormRepo[T any, ID IDConstraint](db *gorm.DB, cfg RepoConfig, opts ...fu
 func (r *GormRepo[T, ID]) WithTx(tx *gorm.DB) Repo[T, ID] {
 	cp := *r
 	cp.db = tx
+	cp.reader = nil // в транзакции читаем то, что пишем
 	return &cp
 }
 
 func (r *GormRepo[T, ID]) GetOne(ctx context.Context, id ID) (T, error) {
+	out, err := r.getOne(ctx, id)
+	if err != nil {
+		return out, err
+	}
+	r.maskFields(ctx, &out)
+	return out, nil
+}
+
+// getOne — GetOne без маскирования полей: политика проверяет запись целиком
+func (r *GormRepo[T, ID]) getOne(ctx context.Context, id ID) (T, error) {
+	ctx, cancel := r.withTimeout(ctx, queryGet)
+	defer cancel()
 	var out T
-	q := r.base(ctx)
+	q := r.readBase(ctx)
 	r.applyPreloads(q)
 	if err := q.Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).First(&out).Error; err != nil {
 		return out, err
 	}
+	if !r.can(ctx, OpRead, out) {
+		var z T
+		return z, fmt.Errorf("%w: %s %v", ErrForbidden, OpRead, id)
+	}
 	return out, nil
 }
 
 func (r *GormRepo[T, ID]) Create(ctx context.Context, in *T) error {
-	return r.base(ctx).Create(in).Error
+	return r.writeTx(ctx, func(ctx context.Context) error {
+		return r.create(ctx, in)
+	})
+}
+
+func (r *GormRepo[T, ID]) create(ctx context.Context, in *T) error {
+	if err := r.checkWriteObj(ctx, in); err != nil {
+		return err
+	}
+	if err := r.stampTenant(ctx, in); err != nil {
+		return err
+	}
+	if err := r.baseFor(ctx, OpCreate).Create(in).Error; err != nil {
+		return err
+	}
+	// in уходит обратно клиенту — скрытые колонки маскируем, как в Save
+	r.maskFields(ctx, in)
+	if !r.tracksChanges() {
+		return nil
+	}
+	id, err := r.idOf(ctx, in)
+	if err != nil {
+		return err
+	}
+	return r.recordChange(ctx, EventCreated, []ID{id})
 }
 
 func (r *GormRepo[T, ID]) Update(ctx context.Context, id ID, patch map[string]any) (T, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) (T, error) {
+		return r.update(ctx, id, patch)
+	})
+}
+
+func (r *GormRepo[T, ID]) update(ctx context.Context, id ID, patch map[string]any) (T, error) {
 	var out T
-	if len(patch) == 0 {
-		return out, errors.New("empty patch")
+	if err := r.checkPatch(ctx, patch); err != nil {
+		return out, err
 	}
-	q := r.base(ctx)
-	if err := q.Model(&out).
+	if err := r.authorize(ctx, OpUpdate, id); err != nil {
+		return out, err
+	}
+	q := r.baseFor(ctx, OpUpdate)
+	tx := q.Model(&out).
 		Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).
 		// Только указанные ключи; GORM защищает от SQL-инъекций на значения
-		Updates(patch).Error; err != nil {
+		Updates(patch)
+	if err := r.checkAffected(ctx, OpUpdate, id, tx); err != nil {
+		return out, err
+	}
+	if err := r.recordChange(ctx, EventUpdated, []ID{id}); err != nil {
 		return out, err
 	}
-	// вернуть свежую запись
-	return r.GetOne(ctx, id)
+	// вернуть свежую запись (реплика могла ещё не догнать)
+	return r.GetOne(ReadPrimary(ctx), id)
 }
 
 func (r *GormRepo[T, ID]) Save(ctx context.Context, id ID, obj T) (T, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) (T, error) {
+		return r.save(ctx, id, obj)
+	})
+}
+
+func (r *GormRepo[T, ID]) save(ctx context.Context, id ID, obj T) (T, error) {
 	var out T
+	if r.policy != nil {
+		if err := r.authorize(ctx, OpUpdate, id); err != nil {
+			return out, err
+		}
+		if err := r.stampTenant(ctx, &obj); err != nil {
+			return out, err
+		}
+		if err := r.setID(ctx, &obj, id); err != nil {
+			return out, err
+		}
+		// Updates вместо Save: Save при 0 затронутых строк делает INSERT, а здесь это обход Restrict
+		tx := r.baseFor(ctx, OpUpdate).Model(&obj).
+			Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).
+			Select("*").Omit(r.saveOmits(ctx)...).Updates(&obj)
+		if err := r.checkAffected(ctx, OpUpdate, id, tx); err != nil {
+			return out, err
+		}
+		if err := r.recordChange(ctx, EventUpdated, []ID{id}); err != nil {
+			return out, err
+		}
+		r.maskFields(ctx, &obj)
+		return obj, nil
+	}
+	if r.cfg.TenantColumn != "" {
+		// Save без совпадения по WHERE превращается в INSERT ... ON CONFLICT — чужую запись так не перезаписать
+		if _, err := r.GetOne(ReadPrimary(ctx), id); err != nil {
+			return out, err
+		}
+		if err := r.stampTenant(ctx, &obj); err != nil {
+			return out, err
+		}
+	}
 	q := r.base(ctx)
+	// скрытые и read-only колонки не перезаписываем
+	if omit := r.saveOmits(ctx); len(omit) > 0 {
+		q = q.Omit(omit...)
+	}
 	if err := q.Model(&obj).Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).
 		Save(obj).Error; err != nil {
 		return out, err
 	}
+	if err := r.recordChange(ctx, EventUpdated, []ID{id}); err != nil {
+		return out, err
+	}
+	r.maskFields(ctx, &obj)
 	return obj, nil
 }
 
 func (r *GormRepo[T, ID]) Delete(ctx context.Context, id ID) error {
-	q := r.base(ctx)
+	return r.writeTx(ctx, func(ctx context.Context) error {
+		return r.delete(ctx, id)
+	})
+}
+
+func (r *GormRepo[T, ID]) delete(ctx context.Context, id ID) error {
+	if err := r.authorize(ctx, OpDelete, id); err != nil {
+		return err
+	}
+	q := r.baseFor(ctx, OpDelete)
 	if r.cfg.UnscopedDelete {
 		q = q.Unscoped()
 	}
 	var z T
-	return q.Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).Delete(&z).Error
+	tx := q.Where(clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}).Delete(&z)
+	if err := r.checkAffected(ctx, OpDelete, id, tx); err != nil {
+		return err
+	}
+	if tx.RowsAffected > 0 {
+		return r.recordChange(ctx, EventDeleted, []ID{id})
+	}
+	return nil
 }
 
 func (r *GormRepo[T, ID]) DeleteMany(ctx context.Context, ids []ID) (int64, error) {
+	return inWriteTx(ctx, r, func(ctx context.Context) (int64, error) {
+		return r.deleteMany(ctx, ids)
+	})
+}
+
+func (r *GormRepo[T, ID]) deleteMany(ctx context.Context, ids []ID) (int64, error) {
 	if len(ids) == 0 {
 		return 0, nil
 	}
-	q := r.base(ctx)
+	if err := r.authorizeMany(ctx, OpDelete, ids); err != nil {
+		return 0, err
+	}
+	q := r.baseFor(ctx, OpDelete)
 	if r.cfg.UnscopedDelete {
 		q = q.Unscoped()
 	}
+	q = q.Where(clause.IN{Column: clause.Column{Name: r.idCol}, Values: toAnySlice(ids)})
+	// чужие (Scopes, тенант, Restrict) и несуществующие ID в событие не попадают
+	changed, err := r.matchingIDs(q)
+	if err != nil {
+		return 0, err
+	}
 	var z T
-	tx := q.Where(clause.IN{Column: clause.Column{Name: r.idCol}, Values: toAnySlice(ids)}).Delete(&z)
-	return tx.RowsAffected, tx.Error
+	tx := q.Delete(&z)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		if err := r.recordChange(ctx, EventDeleted, changed); err != nil {
+			return 0, err
+		}
+	}
+	return tx.RowsAffected, nil
 }
 
 func (r *GormRepo[T, ID]) GetMany(ctx context.Context, ids []ID) ([]T, error) {
+	out, err := r.getMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	r.maskSlice(ctx, out)
+	return out, nil
+}
+
+// getMany — GetMany без маскирования полей
+func (r *GormRepo[T, ID]) getMany(ctx context.Context, ids []ID) ([]T, error) {
+	ctx, cancel := r.withTimeout(ctx, queryGet)
+	defer cancel()
 	var out []T
 	if len(ids) == 0 {
 		return out, nil
 	}
-	q := r.base(ctx)
-	q = r.applyPreloads(q)
-	// IN ? — для всех диалектов
-	if err := q.Where(fmt.Sprintf("%s IN ?", r.idCol), ids).Find(&out).Error; err != nil {
-		return nil, err
+	// IN ? — для всех диалектов; большие списки режем на чанки (лимит параметров драйвера)
+	for _, chunk := range chunkIDs(ids, r.idChunkSize()) {
+		var part []T
+		q := r.applyPreloads(r.readBase(ctx))
+		if err := q.Where(fmt.Sprintf("%s IN ?", r.idCol), chunk).Find(&part).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range part {
+			// запрещённые CanRead записи ведут себя как не найденные
+			if r.can(ctx, OpRead, item) {
+				out = append(out, item)
+			}
+		}
 	}
 	return out, nil
 }
 
+// GetManyOrdered — как GetMany, но Items идут в порядке ids (без дублей),
+// а не найденные (или отсечённые Scopes) ID попадают в Missing.
+func (r *GormRepo[T, ID]) GetManyOrdered(ctx context.Context, ids []ID) (ManyResult[T, ID], error) {
+	var res ManyResult[T, ID]
+	uniq := make([]ID, 0, len(ids))
+	seen := make(map[ID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			uniq = append(uniq, id)
+		}
+	}
+	found, err := r.GetMany(ctx, uniq)
+	if err != nil {
+		return res, err
+	}
+	byID := make(map[ID]T, len(found))
+	for i := range found {
+		id, err := r.idOf(ctx, &found[i])
+		if err != nil {
+			return res, err
+		}
+		byID[id] = found[i]
+	}
+	res.Items = make([]T, 0, len(found))
+	for _, id := range uniq {
+		if item, ok := byID[id]; ok {
+			res.Items = append(res.Items, item)
+		} else {
+			res.Missing = append(res.Missing, id)
+		}
+	}
+	return res, nil
+}
+
 func (r *GormRepo[T, ID]) Count(ctx context.Context) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx, queryGet)
+	defer cancel()
+	var total int64
+	if err := r.readBase(ctx).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// CountWhere — количество записей по фильтрам/поиску (Sort и Pagination игнорируются)
+func (r *GormRepo[T, ID]) CountWhere(ctx context.Context, p ListParams) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx, queryList)
+	defer cancel()
+	q, err := r.applyWhere(ctx, r.readBase(ctx), p)
+	if err != nil {
+		return 0, err
+	}
 	var total int64
-	if err := r.base(ctx).Count(&total).Error; err != nil {
+	if err = q.Count(&total).Error; err != nil {
 		return 0, err
 	}
 	return total, nil
 }
 
+func (r *GormRepo[T, ID]) Exists(ctx context.Context, filters []Filter) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx, queryGet)
+	defer cancel()
+	q, err := r.applyFilters(ctx, r.base(ctx), filters)
+	if err != nil {
+		return false, err
+	}
+	var found []map[string]any
+	if err = q.Select(r.idCol).Limit(1).Find(&found).Error; err != nil {
+		return false, err
+	}
+	return len(found) > 0, nil
+}
+
+// FindOne — первая запись по фильтрам (например, по email или slug).
+// Если ничего не найдено — ошибка, для которой errors.Is(err, ErrNotFound) == true.
+func (r *GormRepo[T, ID]) FindOne(ctx context.Context, filters []Filter) (T, error) {
+	ctx, cancel := r.withTimeout(ctx, queryGet)
+	defer cancel()
+	var out T
+	// [{}] applyFilters пропустит, и запрос вернул бы произвольную строку
+	if countFilters(filters) == 0 {
+		return out, ErrEmptyFilters
+	}
+	q, err := r.applyFilters(ctx, r.base(ctx), filters)
+	if err != nil {
+		return out, err
+	}
+	q = r.applyPreloads(q)
+	if err = q.Order(clause.OrderByColumn{Column: clause.Column{Name: r.idCol}}).Take(&out).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return out, fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+		return out, err
+	}
+	if !r.can(ctx, OpRead, out) {
+		var z T
+		return z, fmt.Errorf("%w: %s", ErrForbidden, OpRead)
+	}
+	r.maskFields(ctx, &out)
+	return out, nil
+}
+
 func (r *GormRepo[T, ID]) GetList(ctx context.Context, p ListParams) (items []T, total int64, err error) {
-	q := r.base(ctx) // base() должен делать db.Model(new(T))
+	ctx, cancel := r.withTimeout(ctx, queryList)
+	defer cancel()
+	q := r.readBase(ctx) // base() должен делать db.Model(new(T))
 
 	// 1) Фильтры
-	if q, err = r.applyFilters(q, p.Filters); err != nil {
+	if q, err = r.applyFilters(ctx, q, p.Filters); err != nil {
 		return nil, 0, err
 	}
 
 	// 2) Поиск
 	if s := strings.TrimSpace(p.Search); s != "" {
-		if q, err = r.applySearch(q, s, p.SearchFields); err != nil {
+		rank := p.Sort == nil || strings.TrimSpace(p.Sort.Field) == ""
+		if q, err = r.applySearch(ctx, q, s, p.SearchFields, rank); err != nil {
 			return nil, 0, err
 		}
 	}
 
 	// 3) Сортировка (не влияет на COUNT, но уже можно навесить здесь)
 	if p.Sort != nil {
-		if q, err = r.applySort(q, *p.Sort); err != nil {
+		if q, err = r.applySort(ctx, q, *p.Sort); err != nil {
 			return nil, 0, err
 		}
 	}
@@ -189,7 +495,7 @@ func (r *GormRepo[T, ID]) GetList(ctx context.Context, p ListParams) (items []T,
 	}
 
 	// 5) Пагинация
-	page, per := sanitizePage(p.Pagination.Page, p.Pagination.PerPage)
+	page, per := sanitizePage(p.Pagination.Page, p.Pagination.PerPage, r.maxPageSize())
 	offset := (page - 1) * per
 
 	// 6) Прелоады и выборка
@@ -197,6 +503,9 @@ func (r *GormRepo[T, ID]) GetList(ctx context.Context, p ListParams) (items []T,
 	if err = q.Limit(per).Offset(offset).Find(&items).Error; err != nil {
 		return nil, 0, err
 	}
+	// CanRead отсекает записи страницы; total считается только по Restrict(OpRead)
+	items = r.readable(ctx, items)
+	r.maskSlice(ctx, items)
 
 	return items, total, nil
 }
@@ -211,12 +520,49 @@ func (r *GormRepo[T, ID]) GetList(ctx context.Context, p ListParams) (items []T,
 //	return q
 //}
 
+// base — запрос на чтение: Scopes + Policy.Restrict(OpRead)
 func (r *GormRepo[T, ID]) base(ctx context.Context) *gorm.DB {
-	q := r.db.Model(new(T)).WithContext(ctx)
+	return r.baseFor(ctx, OpRead)
+}
+
+func (r *GormRepo[T, ID]) baseFor(ctx context.Context, op Operation) *gorm.DB {
+	return r.restrict(ctx, op, r.scoped(ctx, r.conn(ctx)))
+}
+
+// conn — транзакция из ctx (см. TxManager), если она есть, иначе r.db
+func (r *GormRepo[T, ID]) conn(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return r.db.WithContext(ctx)
+}
+
+// scoped — Model + Scopes поверх произвольного соединения (например, транзакции)
+func (r *GormRepo[T, ID]) scoped(ctx context.Context, db *gorm.DB) *gorm.DB {
+	q := db.Model(new(T))
 	for _, s := range r.cfg.Scopes {
 		q = q.Scopes(s)
 	}
-	return q
+	return r.applyContextScopes(ctx, q)
+}
+
+// checkPatch — проверка patch по WritableFields
+func (r *GormRepo[T, ID]) checkPatch(ctx context.Context, patch map[string]any) error {
+	if len(patch) == 0 {
+		return errors.New("empty patch")
+	}
+	for k := range patch {
+		if r.isTenantKey(k) {
+			return ErrTenantChange
+		}
+		if err := r.checkWritable(ctx, k); err != nil {
+			return err
+		}
+		if r.cfg.WritableFields != nil && !r.cfg.WritableFields.Has(k) {
+			return fmt.Errorf("field '%s' is not writable", k)
+		}
+	}
+	return nil
 }
 
 func (r *GormRepo[T, ID]) applyPreloads(db *gorm.DB) *gorm.DB {
@@ -226,7 +572,19 @@ func (r *GormRepo[T, ID]) applyPreloads(db *gorm.DB) *gorm.DB {
 	return db
 }
 
-func (r *GormRepo[T, ID]) applySort(db *gorm.DB, s Sort) (*gorm.DB, error) {
+// applyWhere — фильтры + поиск из ListParams
+func (r *GormRepo[T, ID]) applyWhere(ctx context.Context, db *gorm.DB, p ListParams) (*gorm.DB, error) {
+	db, err := r.applyFilters(ctx, db, p.Filters)
+	if err != nil {
+		return db, err
+	}
+	if s := strings.TrimSpace(p.Search); s != "" {
+		return r.applySearch(ctx, db, s, p.SearchFields, false)
+	}
+	return db, nil
+}
+
+func (r *GormRepo[T, ID]) applySort(ctx context.Context, db *gorm.DB, s Sort) (*gorm.DB, error) {
 	field := strings.TrimSpace(s.Field)
 	if field == "" {
 		return db, nil
@@ -234,6 +592,9 @@ func (r *GormRepo[T, ID]) applySort(db *gorm.DB, s Sort) (*gorm.DB, error) {
 	if !r.cfg.AllowedSortFields.Has(field) {
 		return db, fmt.Errorf("sorting by field '%s' is not allowed", field)
 	}
+	if err := r.checkReadable(ctx, field); err != nil {
+		return db, err
+	}
 	desc := strings.EqualFold(s.Order, "desc")
 	db = db.Order(clause.OrderByColumn{
 		Column: clause.Column{Name: field},
@@ -242,44 +603,63 @@ func (r *GormRepo[T, ID]) applySort(db *gorm.DB, s Sort) (*gorm.DB, error) {
 	return db, nil
 }
 
-// applySearch: без callback-функций, чистая строка + args
-func (r *GormRepo[T, ID]) applySearch(db *gorm.DB, search string, requested []string) (*gorm.DB, error) {
+// applySearch — проверка полей и поиск через RepoConfig.Searcher (по умолчанию LikeSearcher).
+// rank — сортировать по релевантности (в GetList без явной сортировки).
+func (r *GormRepo[T, ID]) applySearch(ctx context.Context, db *gorm.DB, search string, requested []string, rank bool) (*gorm.DB, error) {
+	if err := r.checkSearchFields(requested); err != nil {
+		return db, err
+	}
 	// пересечение с allowed:
 	fields := make([]string, 0, len(requested))
 	if len(requested) > 0 {
 		for _, f := range requested {
 			if r.cfg.AllowedSearchFields.Has(f) {
+				if err := r.checkReadable(ctx, f); err != nil {
+					return db, err
+				}
 				fields = append(fields, f)
 			}
 		}
 	} else {
+		// по умолчанию — только видимые текущему Principal поля
 		for f := range r.cfg.AllowedSearchFields {
-			fields = append(fields, f)
+			if r.canReadField(ctx, f) {
+				fields = append(fields, f)
+			}
 		}
+		sort.Strings(fields)
 	}
 	if len(fields) == 0 {
 		return db, nil
 	}
 
-	like := "%" + search + "%"
-	conds := make([]string, 0, len(fields))
-	args := make([]any, 0, len(fields))
-
-	// Кросс-диалектная регистронезависимость:
-	// - MySQL/SQLite: LOWER() работает
-	// - Postgres: тоже ок
-	for _, f := range fields {
-		conds = append(conds, fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", clause.Column{Name: f}.Name))
-		args = append(args, like)
+	var searcher Searcher = LikeSearcher{}
+	if r.cfg.Searcher != nil {
+		searcher = r.cfg.Searcher
 	}
+	return searcher.Search(db, SearchRequest{
+		Table:      r.tableName(),
+		PK:         r.idCol,
+		Fields:     fields,
+		Query:      search,
+		Rank:       rank,
+		PrefixOnly: r.cfg.NoLeadingWildcard,
+	})
+}
 
-	// (LOWER(f1) LIKE LOWER(?) OR LOWER(f2) LIKE LOWER(?) ...)
-	db = db.Where("("+strings.Join(conds, " OR ")+")", args...)
-	return db, nil
+// tableName — имя таблицы модели (из схемы GORM или TableName())
+func (r *GormRepo[T, ID]) tableName() string {
+	if r.schema != nil {
+		return r.schema.Table
+	}
+	return r.table
 }
 
 // applyFilters: IN/NIN через "field IN ?" и "field NOT IN (?)"
-func (r *GormRepo[T, ID]) applyFilters(db *gorm.DB, filters []Filter) (*gorm.DB, error) {
+func (r *GormRepo[T, ID]) applyFilters(ctx context.Context, db *gorm.DB, filters []Filter) (*gorm.DB, error) {
+	if err := r.checkFilterCount(filters); err != nil {
+		return db, err
+	}
 	for _, f := range filters {
 		field := strings.TrimSpace(f.Field)
 		if field == "" {
@@ -293,6 +673,12 @@ func (r *GormRepo[T, ID]) applyFilters(db *gorm.DB, filters []Filter) (*gorm.DB,
 		if !allowedOps.Has(op) {
 			return db, fmt.Errorf("operator '%s' is not allowed on field '%s'", op, field)
 		}
+		if err := r.checkReadable(ctx, field); err != nil {
+			return db, err
+		}
+		if err := r.checkWildcard(field, op, f.Value); err != nil {
+			return db, err
+		}
 
 		col := clause.Column{Name: field}.Name
 
@@ -309,10 +695,16 @@ func (r *GormRepo[T, ID]) applyFilters(db *gorm.DB, filters []Filter) (*gorm.DB,
 			db = db.Where(fmt.Sprintf("%s > ?", col), f.Value)
 		case "gte":
 			db = db.Where(fmt.Sprintf("%s >= ?", col), f.Value)
-		case "in":
-			db = db.Where(fmt.Sprintf("%s IN ?", col), toAnySliceFromValue(f.Value))
-		case "nin":
-			db = db.Where(fmt.Sprintf("%s NOT IN ?", col), toAnySliceFromValue(f.Value))
+		case "in", "nin":
+			vals := toAnySliceFromValue(f.Value)
+			if err := r.checkInValues(field, vals); err != nil {
+				return db, err
+			}
+			if op == "in" {
+				db = db.Where(fmt.Sprintf("%s IN ?", col), vals)
+			} else {
+				db = db.Where(fmt.Sprintf("%s NOT IN ?", col), vals)
+			}
 		case "between":
 			vals := toAnySliceFromValue(f.Value)
 			if len(vals) != 2 {
@@ -341,15 +733,79 @@ func (r *GormRepo[T, ID]) applyFilters(db *gorm.DB, filters []Filter) (*gorm.DB,
 	return db, nil
 }
 
-func sanitizePage(p, per int) (int, int) {
+// idOf — значение первичного ключа записи, приведённое к ID.
+func (r *GormRepo[T, ID]) idOf(ctx context.Context, obj *T) (ID, error) {
+	var id ID
+	if r.schema == nil {
+		return id, errors.New("model schema is not available")
+	}
+	field := r.schema.LookUpField(r.idCol)
+	if field == nil {
+		return id, fmt.Errorf("primary key field '%s' not found", r.idCol)
+	}
+	v, _ := field.ValueOf(ctx, reflect.ValueOf(obj).Elem())
+	rv := reflect.ValueOf(v)
+	idType := reflect.TypeOf(id)
+	if !rv.IsValid() || !rv.Type().ConvertibleTo(idType) {
+		return id, fmt.Errorf("cannot convert primary key %T to %s", v, idType)
+	}
+	return rv.Convert(idType).Interface().(ID), nil
+}
+
+// setID записывает PK в запись (например, перед Updates целиком)
+func (r *GormRepo[T, ID]) setID(ctx context.Context, obj *T, id ID) error {
+	if r.schema == nil {
+		return errors.New("model schema is not available")
+	}
+	field := r.schema.LookUpField(r.idCol)
+	if field == nil {
+		return fmt.Errorf("primary key field '%s' not found", r.idCol)
+	}
+	return field.Set(ctx, reflect.ValueOf(obj), id)
+}
+
+func (r *GormRepo[T, ID]) idsOf(ctx context.Context, items []T) ([]ID, error) {
+	ids := make([]ID, len(items))
+	for i := range items {
+		id, err := r.idOf(ctx, &items[i])
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+const defaultIDChunkSize = 500
+
+func (r *GormRepo[T, ID]) idChunkSize() int {
+	if r.cfg.IDChunkSize > 0 {
+		return r.cfg.IDChunkSize
+	}
+	return defaultIDChunkSize
+}
+
+func chunkIDs[ID any](ids []ID, size int) [][]ID {
+	chunks := make([][]ID, 0, (len(ids)+size-1)/size)
+	for len(ids) > size {
+		chunks = append(chunks, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+func sanitizePage(p, per, max int) (int, int) {
 	if p <= 0 {
 		p = 1
 	}
 	if per <= 0 {
 		per = 10
 	}
-	if per > 1000 {
-		per = 1000
+	if per > max {
+		per = max
 	}
 	return p, per
 }
@@ -2,6 +2,15 @@ package axcrud
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -120,6 +129,1191 @@ func TestGormRepo_ContextAnsScope(t *testing.T) {
 
 }
 
+func TestGormRepo_CreateManyAndUpsert(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		UpsertConflictColumns: []string{"email"},
+		UpsertUpdateColumns:   []string{"name", "age"},
+	})
+	users := []TestUser{
+		{Name: "Bulk One", Email: "b1@example.com", Role: "user", Age: 20},
+		{Name: "Bulk Two", Email: "b2@example.com", Role: "user", Age: 21},
+		{Name: "Bulk Three", Email: "b3@example.com", Role: "user", Age: 22},
+	}
+	ids, err := repo.CreateMany(ctx, users, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(ids))
+	for i := range ids {
+		assert.Equal(t, users[i].ID, ids[i])
+	}
+
+	// b2 существует — обновится, b4 — вставится
+	upserted, err := repo.UpsertMany(ctx, []TestUser{
+		{Name: "Bulk Two Updated", Email: "b2@example.com", Role: "admin", Age: 40},
+		{Name: "Bulk Four", Email: "b4@example.com", Role: "user", Age: 23},
+	}, UpsertParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ids[1], upserted[0])
+
+	got, err := repo.GetOne(ctx, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bulk Two Updated", got.Name)
+	assert.Equal(t, 40, got.Age)
+	assert.Equal(t, "user", got.Role) // role не в UpdateColumns
+
+	if err = db.Unscoped().Where("id IN ?", append(ids, upserted[1])).Delete(&TestUser{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGormRepo_UpdateMany(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"role": NewFieldSet("eq")},
+		WritableFields:   NewFieldSet("role", "age"),
+	})
+	users := []TestUser{
+		{Name: "Upd One", Email: "upd1@example.com", Role: "guest", Age: 20},
+		{Name: "Upd Two", Email: "upd2@example.com", Role: "guest", Age: 21},
+		{Name: "Upd Three", Email: "upd3@example.com", Role: "guest", Age: 22},
+	}
+	ids, err := repo.CreateMany(ctx, users, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+
+	// удалённая (soft) запись не должна обновляться
+	if err = repo.Delete(ctx, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	affected, err := repo.UpdateMany(ctx, ids, map[string]any{"age": 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2), affected)
+
+	if _, err = repo.UpdateMany(ctx, ids, map[string]any{"name": "x"}); err == nil {
+		t.Fatal("expected error for non-writable field")
+	}
+	for _, empty := range [][]Filter{nil, {{}}, {{Field: "  ", Operator: "eq", Value: "guest"}}} {
+		if _, err = repo.UpdateWhere(ctx, empty, map[string]any{"age": 1}); !errors.Is(err, ErrEmptyFilters) {
+			t.Fatalf("expected ErrEmptyFilters for %v, got %v", empty, err)
+		}
+	}
+
+	affected, err = repo.UpdateWhere(ctx, []Filter{{Field: "role", Operator: "eq", Value: "guest"}}, map[string]any{"role": "archived"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2), affected)
+
+	got, err := repo.GetOne(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "archived", got.Role)
+	assert.Equal(t, 50, got.Age)
+}
+
+func TestGormRepo_DeleteWhere(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"role": NewFieldSet("eq")},
+		UnscopedDelete:   true,
+	})
+	ids, err := repo.CreateMany(ctx, []TestUser{
+		{Name: "Del One", Email: "del1@example.com", Role: "temp"},
+		{Name: "Del Two", Email: "del2@example.com", Role: "temp"},
+		{Name: "Del Three", Email: "del3@example.com", Role: "temp"},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+	temp := []Filter{{Field: "role", Operator: "eq", Value: "temp"}}
+
+	for _, empty := range [][]Filter{nil, {{}}, {{Field: "  ", Operator: "eq", Value: "temp"}}} {
+		if _, err = repo.DeleteWhere(ctx, empty, DeleteWhereOptions{}); !errors.Is(err, ErrEmptyFilters) {
+			t.Fatalf("expected ErrEmptyFilters for %v, got %v", empty, err)
+		}
+	}
+
+	n, err := repo.DeleteWhere(ctx, temp, DeleteWhereOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), n)
+
+	if _, err = repo.DeleteWhere(ctx, temp, DeleteWhereOptions{MaxAffected: 2}); !errors.Is(err, ErrMaxAffectedExceeded) {
+		t.Fatalf("expected ErrMaxAffectedExceeded, got %v", err)
+	}
+	var left int64
+	db.Model(&TestUser{}).Where("role = ?", "temp").Count(&left)
+	assert.Equal(t, int64(3), left) // откатилось
+
+	n, err = repo.DeleteWhere(ctx, temp, DeleteWhereOptions{MaxAffected: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), n)
+}
+
+func TestGormRepo_Aggregate(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps:       map[string]FieldSet{"name": NewFieldSet("startswith")},
+		AllowedAggregateFields: NewFieldSet("age"),
+		AllowedGroupFields:     NewFieldSet("role", "created_at"),
+	})
+	ids, err := repo.CreateMany(ctx, []TestUser{
+		{Name: "Agg One", Email: "agg1@example.com", Role: "admin", Age: 30},
+		{Name: "Agg Two", Email: "agg2@example.com", Role: "user", Age: 20},
+		{Name: "Agg Three", Email: "agg3@example.com", Role: "user", Age: 40},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+
+	p := AggregateParams{
+		ListParams: ListParams{Filters: []Filter{{Field: "name", Operator: "startswith", Value: "Agg"}}},
+		Metrics:    []Metric{{Func: "count"}, {Func: "sum", Field: "age"}},
+		GroupBy:    []string{"role"},
+		Bucket:     &DateBucket{Field: "created_at", Unit: "year"},
+	}
+	rows, err := repo.Aggregate(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "user", rows[1]["role"])
+	assert.Equal(t, int64(2), rows[1]["count"])
+	assert.Equal(t, int64(60), rows[1]["sum_age"])
+	assert.Equal(t, time.Now().Format("2006")+"-01-01", rows[1]["created_at_year"])
+
+	if _, err = repo.Aggregate(ctx, AggregateParams{Metrics: []Metric{{Func: "sum", Field: "email"}}}); err == nil {
+		t.Fatal("expected error for non-whitelisted field")
+	}
+}
+
+func TestGormRepo_Facets(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{
+			"name": NewFieldSet("startswith"),
+			"role": NewFieldSet("eq"),
+		},
+		AllowedFacetFields: NewFieldSet("role", "age"),
+	})
+	ids, err := repo.CreateMany(ctx, []TestUser{
+		{Name: "Facet One", Email: "f1@example.com", Role: "admin", Age: 30},
+		{Name: "Facet Two", Email: "f2@example.com", Role: "user", Age: 30},
+		{Name: "Facet Three", Email: "f3@example.com", Role: "user", Age: 40},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+
+	facets, err := repo.Facets(ctx, ListParams{Filters: []Filter{
+		{Field: "name", Operator: "startswith", Value: "Facet"},
+		{Field: "role", Operator: "eq", Value: "user"},
+	}}, []string{"role", "age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// фасет role не учитывает собственный фильтр role=user
+	assert.Equal(t, []FacetValue{{Value: "user", Count: 2}, {Value: "admin", Count: 1}}, facets["role"])
+	assert.Equal(t, 2, len(facets["age"]))
+
+	if _, err = repo.Facets(ctx, ListParams{}, []string{"email"}); err == nil {
+		t.Fatal("expected error for non-whitelisted field")
+	}
+}
+
+func TestGormRepo_CountExistsFindOne(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{
+			"email": NewFieldSet("eq"),
+			"role":  NewFieldSet("eq"),
+		},
+		AllowedSearchFields: NewFieldSet("name"),
+	})
+	ids, err := repo.CreateMany(ctx, []TestUser{
+		{Name: "Find Alice", Email: "find-alice@example.com", Role: "finder"},
+		{Name: "Find Bob", Email: "find-bob@example.com", Role: "finder"},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+	finders := []Filter{{Field: "role", Operator: "eq", Value: "finder"}}
+
+	n, err := repo.CountWhere(ctx, ListParams{Filters: finders, Search: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), n)
+
+	ok, err := repo.Exists(ctx, finders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, ok)
+
+	got, err := repo.FindOne(ctx, []Filter{{Field: "email", Operator: "eq", Value: "find-bob@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ids[1], got.ID)
+
+	_, err = repo.FindOne(ctx, []Filter{{Field: "email", Operator: "eq", Value: "nobody@example.com"}})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, empty := range [][]Filter{nil, {{}}, {{Field: " ", Operator: "eq", Value: "x"}}} {
+		if _, err = repo.FindOne(ctx, empty); !errors.Is(err, ErrEmptyFilters) {
+			t.Fatalf("expected ErrEmptyFilters for %v, got %v", empty, err)
+		}
+	}
+}
+
+func TestGormRepo_GetManyOrdered(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{IDChunkSize: 2})
+	ids, err := repo.CreateMany(ctx, []TestUser{
+		{Name: "Many One", Email: "many1@example.com"},
+		{Name: "Many Two", Email: "many2@example.com"},
+		{Name: "Many Three", Email: "many3@example.com"},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+
+	const absent = uint(1 << 30)
+	res, err := repo.GetManyOrdered(ctx, []uint{ids[2], absent, ids[0], ids[2], ids[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(res.Items))
+	assert.Equal(t, ids[2], res.Items[0].ID)
+	assert.Equal(t, ids[0], res.Items[1].ID)
+	assert.Equal(t, ids[1], res.Items[2].ID)
+	assert.Equal(t, []uint{absent}, res.Missing)
+}
+
+func TestGormRepo_Iterate(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"role": NewFieldSet("eq")},
+	})
+	users := make([]TestUser, 7)
+	for i := range users {
+		users[i] = TestUser{Name: fmt.Sprintf("Iter %d", i), Email: fmt.Sprintf("iter%d@example.com", i), Role: "iter"}
+	}
+	ids, err := repo.CreateMany(ctx, users, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Where("id IN ?", ids).Delete(&TestUser{})
+	p := ListParams{Filters: []Filter{{Field: "role", Operator: "eq", Value: "iter"}}}
+
+	var batches int
+	var seen []uint
+	processed, err := repo.Iterate(ctx, p, 3, func(batch []TestUser) error {
+		batches++
+		for _, u := range batch {
+			seen = append(seen, u.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(7), processed)
+	assert.Equal(t, 3, batches)
+	assert.Equal(t, ids, seen)
+
+	var n int
+	for u, err := range repo.All(ctx, p, 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; n == 4 {
+			assert.Equal(t, ids[3], u.ID)
+			break
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err = repo.Iterate(cancelled, p, 3, func([]TestUser) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTxManager_RunInTx(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{})
+	tm := NewTxManager(db)
+	boom := errors.New("boom")
+
+	var outerID, innerID uint
+	err := tm.RunInTx(ctx, func(txCtx context.Context) error {
+		outer := TestUser{Name: "Tx Outer", Email: "tx-outer@example.com"}
+		if err := repo.Create(txCtx, &outer); err != nil {
+			return err
+		}
+		outerID = outer.ID
+		// вложенная транзакция (SAVEPOINT) откатывается, внешняя — нет
+		err := tm.RunInTx(txCtx, func(innerCtx context.Context) error {
+			inner := TestUser{Name: "Tx Inner", Email: "tx-inner@example.com"}
+			if err := repo.Create(innerCtx, &inner); err != nil {
+				return err
+			}
+			innerID = inner.ID
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Fatalf("expected boom, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, outerID)
+	if _, err = repo.GetOne(ctx, outerID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.GetOne(ctx, innerID); err == nil {
+		t.Fatal("expected inner insert to be rolled back")
+	}
+
+	if err = tm.RunInTx(ctx, func(txCtx context.Context) error {
+		if err := repo.Create(txCtx, &TestUser{Name: "Tx Gone", Email: "tx-gone@example.com"}); err != nil {
+			return err
+		}
+		return boom
+	}); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	var n int64
+	db.Model(&TestUser{}).Where("email = ?", "tx-gone@example.com").Count(&n)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestGormRepo_Tenant(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{TenantColumn: "user_id"})
+	ctxA := WithTenantID(ctx, uint(101))
+	ctxB := WithTenantID(ctx, uint(102))
+
+	a := TestUser{Name: "Tenant A", Email: "tenant-a@example.com"}
+	if err := repo.Create(ctxA, &a); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, a.ID)
+	assert.Equal(t, uint(101), a.UserID)
+
+	if err := repo.Create(ctx, &TestUser{Name: "No Tenant", Email: "tenant-none@example.com"}); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+	if _, err := repo.GetOne(ctx, a.ID); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+
+	if _, err := repo.GetOne(ctxB, a.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found for other tenant, got %v", err)
+	}
+	if _, err := repo.Update(ctxB, a.ID, map[string]any{"name": "Hijack"}); err == nil {
+		t.Fatal("expected update from other tenant to fail")
+	}
+	if _, err := repo.Save(ctxB, a.ID, TestUser{Name: "Hijack", Email: "tenant-a@example.com"}); err == nil {
+		t.Fatal("expected save from other tenant to fail")
+	}
+	if _, err := repo.Update(ctxA, a.ID, map[string]any{"user_id": 102}); !errors.Is(err, ErrTenantChange) {
+		t.Fatalf("expected ErrTenantChange, got %v", err)
+	}
+	if _, err := repo.Update(ctxA, a.ID, map[string]any{"UserID": 102}); !errors.Is(err, ErrTenantChange) {
+		t.Fatalf("expected ErrTenantChange, got %v", err)
+	}
+
+	got, err := repo.Update(ctxA, a.ID, map[string]any{"name": "Tenant A2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Tenant A2", got.Name)
+	assert.Equal(t, uint(101), got.UserID)
+
+	n, err := repo.DeleteMany(ctxB, []uint{a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), n)
+}
+
+// noConflictWhere — SQLite под именем диалекта без ON CONFLICT ... WHERE
+type noConflictWhere struct {
+	gorm.Dialector
+}
+
+func (noConflictWhere) Name() string {
+	return "mysql"
+}
+
+func TestGormRepo_TenantUpsert(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{TenantColumn: "user_id", UpsertConflictColumns: []string{"email"}})
+	t1, t2 := WithTenantID(ctx, uint(301)), WithTenantID(ctx, uint(302))
+
+	u := TestUser{Name: "Tenant A", Email: "tenant-upsert@example.com"}
+	if err := repo.Create(t1, &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	oc := repo.onConflict(t2, UpsertParams{})
+	for _, a := range oc.DoUpdates {
+		if a.Column.Name == "user_id" {
+			t.Fatal("tenant column must not be updated on conflict")
+		}
+	}
+	oc = repo.onConflict(t2, UpsertParams{UpdateColumns: []string{"name", "user_id"}})
+	assert.Equal(t, 1, len(oc.DoUpdates))
+
+	// SQLite: WHERE в ON CONFLICT не даёт обновить чужую запись
+	_ = repo.Upsert(t2, &TestUser{Name: "Tenant B", Email: u.Email}, UpsertParams{})
+	var got TestUser
+	db.First(&got, u.ID)
+	assert.Equal(t, "Tenant A", got.Name)
+	assert.Equal(t, uint(301), got.UserID)
+
+	// диалект без ON CONFLICT ... WHERE — конфликт с чужой записью ищется заранее
+	other, err := gorm.Open(noConflictWhere{sqlite.Open(":memory:")}, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = other.AutoMigrate(&TestUser{}); err != nil {
+		t.Fatal(err)
+	}
+	mysqlLike := NewGormRepo[TestUser, uint](other, RepoConfig{TenantColumn: "user_id", UpsertConflictColumns: []string{"email"}})
+	v := TestUser{Name: "Tenant A", Email: u.Email}
+	if err = mysqlLike.Create(t1, &v); err != nil {
+		t.Fatal(err)
+	}
+	if err = mysqlLike.checkUpsertTenant(t2, []TestUser{{Email: u.Email}}, UpsertParams{}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if err = mysqlLike.checkUpsertTenant(t1, []TestUser{{Email: u.Email}}, UpsertParams{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGormRepo_ContextScopes(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	errNoUser := errors.New("no principal")
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		ContextScopes: []ContextScope{func(ctx context.Context, q *gorm.DB) (*gorm.DB, error) {
+			p, ok := PrincipalFromContext(ctx)
+			if !ok {
+				return nil, errNoUser
+			}
+			if p.HasRole("admin") {
+				return q, nil
+			}
+			return q.Where("role = ?", "public"), nil
+		}},
+	})
+
+	u := TestUser{Name: "Scoped", Email: "ctx-scope@example.com", Role: "secret"}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	if _, err := repo.GetOne(ctx, u.ID); !errors.Is(err, errNoUser) {
+		t.Fatalf("expected scope error, got %v", err)
+	}
+	if _, err := repo.GetOne(WithPrincipal(ctx, Principal{ID: 1}), u.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := repo.GetOne(WithPrincipal(ctx, Principal{ID: 1, Roles: []string{"admin"}}), u.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ownerPolicy: читать могут все, менять — владелец (UserID), удалять — admin
+type ownerPolicy struct {
+	AllowAll[TestUser]
+}
+
+func (ownerPolicy) Restrict(ctx context.Context, op Operation, db *gorm.DB) (*gorm.DB, error) {
+	p, _ := PrincipalFromContext(ctx)
+	switch {
+	case op == OpUpdate && !p.HasRole("admin"):
+		return db.Where("user_id = ?", p.ID), nil
+	case op == OpDelete && !p.HasRole("admin"):
+		return db.Where("1 = 0"), nil
+	}
+	return db, nil
+}
+
+func (ownerPolicy) CanUpdate(ctx context.Context, u TestUser) bool {
+	p, _ := PrincipalFromContext(ctx)
+	return p.HasRole("admin") || p.ID == u.UserID
+}
+
+func (ownerPolicy) CanDelete(ctx context.Context, _ TestUser) bool {
+	p, _ := PrincipalFromContext(ctx)
+	return p.HasRole("admin")
+}
+
+func TestGormRepo_Policy(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{}, WithPolicy[TestUser, uint](ownerPolicy{}))
+	owner := WithPrincipal(ctx, Principal{ID: uint(201)})
+	other := WithPrincipal(ctx, Principal{ID: uint(202)})
+	admin := WithPrincipal(ctx, Principal{ID: uint(1), Roles: []string{"admin"}})
+
+	u := TestUser{Name: "Owned", Email: "policy-owned@example.com", UserID: 201}
+	if err := repo.Create(owner, &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	if _, err := repo.GetOne(other, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Update(other, u.ID, map[string]any{"name": "Stolen"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if _, err := repo.Save(other, u.ID, TestUser{Name: "Stolen", Email: "policy-owned@example.com"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if _, err := repo.UpdateMany(other, []uint{u.ID}, map[string]any{"name": "Stolen"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	got, err := repo.Update(owner, u.ID, map[string]any{"name": "Owned 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Owned 2", got.Name)
+
+	if err = repo.Delete(owner, u.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if _, err = repo.Update(other, 999999, map[string]any{"name": "x"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err = repo.Delete(admin, u.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// restrictOnlyPolicy — запрет на изменение только через Restrict (CanUpdate разрешает всё)
+type restrictOnlyPolicy struct {
+	ownerPolicy
+}
+
+func (restrictOnlyPolicy) CanUpdate(context.Context, TestUser) bool { return true }
+
+// TestGormRepo_PolicyNoopUpdate — 0 затронутых строк (MySQL при неизменных значениях) не 403,
+// если запись видна под Restrict(OpUpdate)
+func TestGormRepo_PolicyNoopUpdate(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Update().After("gorm:update").Register("test:mysql_noop", func(tx *gorm.DB) {
+		tx.RowsAffected = 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{}, WithPolicy[TestUser, uint](restrictOnlyPolicy{}))
+	owner := WithPrincipal(ctx, Principal{ID: uint(201)})
+	u := TestUser{Name: "Same", Email: "noop@example.com", UserID: 201}
+	db.Create(&u)
+
+	if _, err = repo.Update(owner, u.ID, map[string]any{"name": "Same"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.Save(owner, u.ID, u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.Update(WithPrincipal(ctx, Principal{ID: uint(202)}), u.ID, map[string]any{"name": "Same"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+// lockedPolicy — Restrict ничего не сужает, запрет только в Can* (role = "locked")
+type lockedPolicy struct {
+	AllowAll[TestUser]
+}
+
+func (lockedPolicy) CanRead(_ context.Context, u TestUser) bool {
+	return u.Role != "secret"
+}
+
+func (lockedPolicy) CanUpdate(_ context.Context, u TestUser) bool {
+	return u.Role != "locked"
+}
+
+func (lockedPolicy) CanDelete(_ context.Context, u TestUser) bool {
+	return u.Role != "locked"
+}
+
+func TestGormRepo_PolicyBulk(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"email": NewFieldSet("startswith")},
+	}, WithPolicy[TestUser, uint](lockedPolicy{}))
+
+	locked := TestUser{Name: "Locked", Email: "policy-bulk-locked@example.com", Role: "locked"}
+	open := TestUser{Name: "Open", Email: "policy-bulk-open@example.com", Role: "staff"}
+	for _, u := range []*TestUser{&locked, &open} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	}
+	filters := []Filter{{Field: "email", Operator: "startswith", Value: "policy-bulk-"}}
+
+	if _, err := repo.UpdateWhere(ctx, filters, map[string]any{"name": "Changed"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if _, err := repo.DeleteWhere(ctx, filters, DeleteWhereOptions{}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	var names []string
+	db.Model(&TestUser{}).Where("email LIKE ?", "policy-bulk-%").Order("id").Pluck("name", &names)
+	assert.Equal(t, []string{"Locked", "Open"}, names) // ничего не изменено
+
+	n, err := repo.UpdateWhere(ctx, []Filter{{Field: "email", Operator: "startswith", Value: "policy-bulk-open"}}, map[string]any{"name": "Changed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), n)
+
+	// ON CONFLICT DO UPDATE по email не обходит CanUpdate
+	byEmail := UpsertParams{ConflictColumns: []string{"email"}}
+	hijack := TestUser{Name: "Hijacked", Email: locked.Email, Role: "admin"}
+	if err = repo.Upsert(ctx, &hijack, byEmail); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if _, err = repo.UpsertMany(ctx, []TestUser{{Name: "Hijacked", Email: locked.Email}}, byEmail); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	var got TestUser
+	db.First(&got, locked.ID)
+	assert.Equal(t, "locked", got.Role)
+
+	fresh := TestUser{Name: "Fresh", Email: "policy-bulk-fresh@example.com"}
+	if err = repo.Upsert(ctx, &fresh, byEmail); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, fresh.ID)
+	upd := TestUser{Name: "Open 2", Email: open.Email, Role: "staff"}
+	if err = repo.Upsert(ctx, &upd, byEmail); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGormRepo_PolicyRead(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"email": NewFieldSet("startswith")},
+	}, WithPolicy[TestUser, uint](lockedPolicy{}))
+
+	secret := TestUser{Name: "Secret", Email: "policy-read-secret@example.com", Role: "secret"}
+	public := TestUser{Name: "Public", Email: "policy-read-public@example.com", Role: "staff"}
+	for _, u := range []*TestUser{&secret, &public} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	}
+	if _, err := repo.GetOne(ctx, secret.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	p := ListParams{Filters: []Filter{{Field: "email", Operator: "startswith", Value: "policy-read-"}}}
+	items, _, err := repo.GetList(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, "Public", items[0].Name)
+
+	var seen []string
+	n, err := repo.Iterate(ctx, p, 1, func(batch []TestUser) error {
+		for _, u := range batch {
+			seen = append(seen, u.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, []string{"Public"}, seen)
+}
+
+// adultPolicy — менять можно записи с Age >= 18 (Age при этом может быть скрыт FieldAccess)
+type adultPolicy struct {
+	AllowAll[TestUser]
+}
+
+func (adultPolicy) CanUpdate(_ context.Context, u TestUser) bool { return u.Age >= 18 }
+
+// TestGormRepo_FieldAccessPolicy — политика решает по записи без маскирования скрытых полей
+func TestGormRepo_FieldAccessPolicy(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		FieldAccess: map[string]FieldAccess{"age": {ReadRoles: []string{"hr"}}},
+	}, WithPolicy[TestUser, uint](adultPolicy{}))
+	user := WithPrincipal(ctx, Principal{ID: 1})
+
+	u := TestUser{Name: "Adult", Email: "field-access-policy@example.com", Age: 42}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	got, err := repo.Update(user, u.ID, map[string]any{"name": "Adult 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, got.Age)
+	if _, err = repo.UpdateMany(user, []uint{u.ID}, map[string]any{"name": "Adult 3"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGormRepo_FieldAccess(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps:  map[string]FieldSet{"age": NewFieldSet("gte"), "name": NewFieldSet("eq")},
+		AllowedSortFields: NewFieldSet("age"),
+		FieldAccess: map[string]FieldAccess{
+			"age":  {ReadRoles: []string{"hr"}},
+			"role": {WriteRoles: []string{"admin"}},
+		},
+	})
+	user := WithPrincipal(ctx, Principal{ID: 1})
+	hr := WithPrincipal(ctx, Principal{ID: 2, Roles: []string{"hr"}})
+
+	u := TestUser{Name: "Field Access", Email: "field-access@example.com", Age: 42, Role: "staff"}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	got, err := repo.GetOne(user, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, got.Age)
+	assert.Equal(t, "staff", got.Role)
+	if got, err = repo.GetOne(hr, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 42, got.Age)
+
+	if _, _, err = repo.GetList(user, ListParams{Filters: []Filter{{Field: "age", Operator: "gte", Value: 40}}}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on filter, got %v", err)
+	}
+	if _, _, err = repo.GetList(user, ListParams{Sort: &Sort{Field: "age"}}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on sort, got %v", err)
+	}
+	if _, _, err = repo.GetList(hr, ListParams{Filters: []Filter{{Field: "age", Operator: "gte", Value: 40}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = repo.Update(user, u.ID, map[string]any{"role": "admin"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on write, got %v", err)
+	}
+	if err = repo.Create(user, &TestUser{Name: "Self Admin", Email: "field-access-2@example.com", Role: "admin"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden on create, got %v", err)
+	}
+
+	// Save от пользователя без hr не затирает скрытый age и read-only role
+	got.Name = "Field Access 2"
+	got.Age = 0
+	got.Role = "admin"
+	if _, err = repo.Save(user, u.ID, got); err != nil {
+		t.Fatal(err)
+	}
+	var raw TestUser
+	db.First(&raw, u.ID)
+	assert.Equal(t, "Field Access 2", raw.Name)
+	assert.Equal(t, 42, raw.Age)
+	assert.Equal(t, "staff", raw.Role)
+
+	// Create/CreateMany/Upsert возвращают объект с теми же масками, что и чтение
+	created := TestUser{Name: "Masked Create", Email: "field-access-3@example.com", Age: 30}
+	if err = repo.Create(user, &created); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, created.ID)
+	assert.Equal(t, 0, created.Age)
+	many := []TestUser{{Name: "Masked Many", Email: "field-access-4@example.com", Age: 31}}
+	ids, err := repo.CreateMany(user, many, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, ids)
+	assert.Equal(t, 0, many[0].Age)
+	upserted := TestUser{ID: created.ID, Name: "Masked Upsert", Email: created.Email, Age: 33}
+	if err = repo.Upsert(user, &upserted, UpsertParams{}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, upserted.Age)
+	var stored TestUser
+	db.First(&stored, created.ID)
+	assert.Equal(t, 33, stored.Age)
+}
+
+func TestGormRepo_Events(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	broker := NewBroker(10)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{}, WithEvents[TestUser, uint](broker, "users"))
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	live := broker.Subscribe(subCtx, 0, nil)
+
+	u := TestUser{Name: "Evented", Email: "events@example.com"}
+	if err := repo.Create(WithTenantID(ctx, uint(7)), &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	if _, err := repo.Update(ctx, u.ID, map[string]any{"name": "Evented 2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// rollback — события нет
+	tm := NewTxManager(db)
+	_ = tm.RunInTx(ctx, func(txCtx context.Context) error {
+		if _, err := repo.Update(txCtx, u.ID, map[string]any{"name": "Rolled back"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err := repo.Delete(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Event
+	for range 3 {
+		select {
+		case e := <-live:
+			got = append(got, e)
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+	assert.Equal(t, EventCreated, got[0].Type)
+	assert.Equal(t, "users", got[0].Resource)
+	assert.Equal(t, []any{u.ID}, got[0].IDs)
+	assert.Equal(t, uint(7), got[0].TenantID)
+	assert.Equal(t, EventUpdated, got[1].Type)
+	assert.Equal(t, EventDeleted, got[2].Type)
+	select {
+	case e := <-live:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+
+	// переподключение с Last-Event-ID получает пропущенное из буфера
+	replay := broker.Subscribe(subCtx, got[0].ID, func(e Event) bool { return e.Type == EventDeleted })
+	assert.Equal(t, got[2].ID, (<-replay).ID)
+}
+
+// TestGormRepo_EventsAffectedIDs — в событии только реально затронутые строки,
+// без чужих (Scopes) и несуществующих ID; у *Where — тоже с ID
+func TestGormRepo_EventsAffectedIDs(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	broker := NewBroker(10)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"role": NewFieldSet("eq")},
+		WritableFields:   NewFieldSet("age"),
+		Scopes:           []func(*gorm.DB) *gorm.DB{func(q *gorm.DB) *gorm.DB { return q.Where("user_id = ?", 41) }},
+	}, WithEvents[TestUser, uint](broker, "users"))
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	live := broker.Subscribe(subCtx, 0, nil)
+
+	mine := TestUser{Name: "Mine", Email: "affected-1@example.com", Role: "affected", UserID: 41}
+	other := TestUser{Name: "Other", Email: "affected-2@example.com", Role: "affected", UserID: 42}
+	db.Create(&mine)
+	db.Create(&other)
+	defer db.Unscoped().Delete(&TestUser{}, []uint{mine.ID, other.ID})
+
+	if _, err := repo.UpdateMany(ctx, []uint{mine.ID, other.ID, 999999}, map[string]any{"age": 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateWhere(ctx, []Filter{{Field: "role", Operator: "eq", Value: "affected"}}, map[string]any{"age": 6}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.DeleteMany(ctx, []uint{mine.ID, other.ID, 999999}); err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []EventType{EventUpdated, EventUpdated, EventDeleted} {
+		select {
+		case e := <-live:
+			assert.Equal(t, typ, e.Type)
+			assert.Equal(t, []any{mine.ID}, e.IDs)
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+}
+
+type flakyPublisher struct {
+	fails int
+	calls int
+}
+
+func (p *flakyPublisher) Publish(context.Context, OutboxMessage) error {
+	p.calls++
+	if p.calls <= p.fails {
+		return errors.New("broker down")
+	}
+	return nil
+}
+
+func TestGormRepo_Outbox(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	if err := MigrateOutbox(db); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Where("1 = 1").Delete(&OutboxMessage{})
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{}, WithOutbox[TestUser, uint]("users"))
+
+	u := TestUser{Name: "Outboxed", Email: "outbox@example.com"}
+	if err := repo.Create(WithTenantID(ctx, 3), &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	// откат транзакции откатывает и строку outbox
+	tm := NewTxManager(db)
+	_ = tm.RunInTx(ctx, func(txCtx context.Context) error {
+		if _, err := repo.Update(txCtx, u.ID, map[string]any{"name": "Rolled back"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+
+	var rows []OutboxMessage
+	db.Order("id").Find(&rows)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, EventCreated, rows[0].Type)
+	assert.Equal(t, "3", rows[0].TenantID)
+	assert.Equal(t, fmt.Sprintf("[%d]", u.ID), string(rows[0].IDs))
+	assert.Equal(t, OutboxPending, rows[0].Status)
+
+	pub := NewMemoryPublisher()
+	relay := NewRelay(db, pub, RelayOptions{})
+	n, err := relay.ProcessBatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, n)
+	assert.Equal(t, rows[0].IdempotencyKey, pub.Messages()[0].IdempotencyKey)
+	if n, _ = relay.ProcessBatch(ctx); n != 0 {
+		t.Fatalf("expected nothing to deliver, got %d", n)
+	}
+
+	// повторы с backoff, затем dead
+	if err = repo.Delete(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyPublisher{fails: 100}
+	relay = NewRelay(db, flaky, RelayOptions{MaxAttempts: 2, BaseBackoff: time.Millisecond})
+	if _, err = relay.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var msg OutboxMessage
+	db.Where("type = ?", EventDeleted).First(&msg)
+	assert.Equal(t, 1, msg.Attempts)
+	assert.Equal(t, OutboxPending, msg.Status)
+	assert.Equal(t, "broker down", msg.LastError)
+	time.Sleep(5 * time.Millisecond)
+	if _, err = relay.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&msg, msg.ID)
+	assert.Equal(t, OutboxDead, msg.Status)
+}
+
+func TestRelay_LogsBatchErrors(t *testing.T) {
+	var buf strings.Builder
+	q := newWorkQueue(RelayOptions{PollInterval: time.Millisecond, WorkerID: "w1", Logger: slog.New(slog.NewTextHandler(&buf, nil))})
+	runCtx, cancel := context.WithCancel(ctx)
+	calls := 0
+	err := q.run(runCtx, func(context.Context) (int, error) {
+		if calls++; calls == 2 {
+			cancel()
+		}
+		return 0, errors.New("db is down")
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, true, strings.Contains(buf.String(), "error=\"db is down\""))
+	assert.Equal(t, true, strings.Contains(buf.String(), "worker=w1"))
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var got OutboxMessage
+	var key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("Idempotency-Key")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	msg := OutboxMessage{ID: 1, IdempotencyKey: "k1", Resource: "users", Type: EventCreated, IDs: json.RawMessage("[1]")}
+	pub := &WebhookPublisher{URL: srv.URL}
+	if err := pub.Publish(ctx, msg); err == nil {
+		t.Fatal("expected error on 401")
+	}
+	pub.Headers = map[string]string{"X-Token": "secret"}
+	if err := pub.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "k1", key)
+	assert.Equal(t, "users", got.Resource)
+	assert.Equal(t, "[1]", string(got.IDs))
+}
+
+func TestWebhooks(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	if err := MigrateWebhooks(db); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Where("1 = 1").Delete(&WebhookDelivery{})
+	defer db.Where("1 = 1").Delete(&WebhookSubscription{})
+
+	fail := true
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if !VerifyWebhook("s3cret", ts, body, r.Header.Get(WebhookSignatureHeader), time.Minute) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		bodies = append(bodies, body)
+	}))
+	defer srv.Close()
+
+	tctx := WithTenantID(ctx, "t1")
+	// по умолчанию loopback/private/link-local запрещены и при сохранении, и при подключении
+	strict := NewWebhooks(db, WebhookOptions{RelayOptions: RelayOptions{MaxAttempts: 1}})
+	for _, u := range []string{srv.URL, "http://localhost:8080/hook", "http://169.254.169.254/latest/meta-data", "http://10.0.0.1/", "http://[::1]/"} {
+		if err := strict.Subscriptions().Create(tctx, &WebhookSubscription{URL: u, Secret: "x"}); err == nil {
+			t.Fatalf("expected %s to be rejected", u)
+		}
+	}
+	if _, err := strict.send(ctx, WebhookSubscription{URL: srv.URL, Secret: "x"}, WebhookDelivery{}); err == nil {
+		t.Fatal("expected dial to loopback to be rejected")
+	}
+
+	wh := NewWebhooks(db, WebhookOptions{RelayOptions: RelayOptions{MaxAttempts: 3, BaseBackoff: time.Millisecond}, AllowPrivateNetworks: true})
+	sub := WebhookSubscription{URL: srv.URL, Secret: "s3cret", Resources: "orders", Events: "created,updated"}
+	if err := wh.Subscriptions().Create(tctx, &sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := wh.Subscriptions().Create(tctx, &WebhookSubscription{URL: "ftp://x", Secret: "x"}); err == nil {
+		t.Fatal("expected invalid url error")
+	}
+	got, err := wh.Subscriptions().GetOne(tctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "", got.Secret)
+	assert.Equal(t, "t1", got.TenantID)
+	// подписки и журнал другого тенанта не видны
+	if _, err = wh.Subscriptions().GetOne(WithTenantID(ctx, "t2"), sub.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected ErrNotFound for another tenant, got %v", err)
+	}
+	if _, _, err = wh.Subscriptions().GetList(ctx, ListParams{}); !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+	if _, err = wh.Subscriptions().Update(tctx, sub.ID, map[string]any{"url": "not a url"}); err == nil {
+		t.Fatal("expected invalid url error on update")
+	}
+	if _, err = wh.Subscriptions().Update(tctx, sub.ID, map[string]any{"events": "created,updated"}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := OutboxMessage{IdempotencyKey: "evt-1", Resource: "orders", Type: EventCreated, TenantID: "t1", IDs: json.RawMessage("[10]")}
+	for range 2 { // повтор Relay не создаёт дублей
+		if err = wh.Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = wh.Publish(ctx, OutboxMessage{IdempotencyKey: "evt-2", Resource: "orders", Type: EventDeleted}); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Model(&WebhookDelivery{}).Count(&n)
+	assert.Equal(t, int64(1), n)
+
+	if _, err = wh.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var d WebhookDelivery
+	db.First(&d)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, d.LastStatusCode)
+	assert.Equal(t, OutboxPending, d.Status)
+
+	db.Model(&WebhookDelivery{}).Where("id = ?", d.ID).Update("status", OutboxDead)
+	_, total, err := wh.Deliveries().GetList(WithTenantID(ctx, "t2"), ListParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), total)
+	if err = wh.Redeliver(WithTenantID(ctx, "t2"), d.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for another tenant, got %v", err)
+	}
+	if err = wh.Redeliver(tctx, d.ID); err != nil {
+		t.Fatal(err)
+	}
+	fail = false
+	if _, err = wh.ProcessBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&d, d.ID)
+	assert.Equal(t, OutboxDone, d.Status)
+	assert.Equal(t, 1, len(bodies))
+	var delivered OutboxMessage
+	_ = json.Unmarshal(bodies[0], &delivered)
+	assert.Equal(t, "evt-1", delivered.IdempotencyKey)
+
+	if err = wh.Redeliver(tctx, 999999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	db, err := setupTestDB()
 	ctx = context.WithValue(context.Background(), "db", db)
@@ -139,3 +1333,400 @@ func setupTestDB() (*gorm.DB, error) {
 	}
 	return db, nil
 }
+
+func TestGormRepo_Versioning(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	if err := MigrateVersions(db); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Where("1 = 1").Delete(&RecordVersion{})
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"email": NewFieldSet("eq")},
+	}, WithVersioning[TestUser, uint]("users"))
+	uctx := WithPrincipal(ctx, Principal{ID: 7})
+
+	u := TestUser{Name: "Contract", Email: "contract@example.com", Age: 1}
+	if err := repo.Create(uctx, &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	beforeUpdate := time.Now()
+	if _, err := repo.Update(uctx, u.ID, map[string]any{"age": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateWhere(uctx, []Filter{{Field: "email", Operator: "eq", Value: "contract@example.com"}}, map[string]any{"name": "Contract v3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	vs, err := repo.Versions(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(vs))
+	assert.Equal(t, EventCreated, vs[0].Op)
+	assert.Equal(t, "7", vs[0].ChangedBy)
+	assert.Equal(t, 2, vs[1].Data.Age)
+	assert.Equal(t, "Contract v3", vs[2].Data.Name)
+
+	old, err := repo.AsOf(ctx, u.ID, beforeUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, old.Age)
+
+	changes, err := repo.Diff(ctx, u.ID, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]bool{}
+	for _, c := range changes {
+		fields[c.Field] = true
+	}
+	assert.Equal(t, true, fields["Age"])
+	assert.Equal(t, true, fields["Name"])
+	assert.Equal(t, false, fields["Email"])
+
+	reverted, err := repo.Revert(ctx, u.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Contract", reverted.Name)
+	assert.Equal(t, 1, reverted.Age)
+
+	if err = repo.Delete(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	vs, _ = repo.Versions(ctx, u.ID)
+	assert.Equal(t, 5, len(vs))
+	assert.Equal(t, EventDeleted, vs[4].Op)
+	if _, err = repo.AsOf(ctx, u.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	plain := NewGormRepo[TestUser, uint](db, RepoConfig{})
+	if _, err = plain.Versions(ctx, u.ID); !errors.Is(err, ErrVersioningDisabled) {
+		t.Fatalf("expected ErrVersioningDisabled, got %v", err)
+	}
+
+	// история видна только тем, кто видит саму запись
+	owned := NewGormRepo[TestUser, uint](db, RepoConfig{
+		ContextScopes: []ContextScope{func(ctx context.Context, q *gorm.DB) (*gorm.DB, error) {
+			p, _ := PrincipalFromContext(ctx)
+			return q.Where("user_id = ?", p.ID), nil
+		}},
+	}, WithVersioning[TestUser, uint]("users"))
+	mine := TestUser{Name: "Mine", Email: "versions-owned@example.com", UserID: 501}
+	if err = owned.Create(WithPrincipal(ctx, Principal{ID: uint(501)}), &mine); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, mine.ID)
+	if vs, err = owned.Versions(WithPrincipal(ctx, Principal{ID: uint(501)}), mine.ID); err != nil || len(vs) != 1 {
+		t.Fatalf("owner: %d versions, %v", len(vs), err)
+	}
+	if _, err = owned.Versions(WithPrincipal(ctx, Principal{ID: uint(502)}), mine.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err = owned.AsOf(WithPrincipal(ctx, Principal{ID: uint(502)}), mine.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// версии пишутся только для строк своего тенанта и нумеруются в его пределах
+	tenanted := NewGormRepo[TestUser, uint](db, RepoConfig{
+		TenantColumn:   "user_id",
+		WritableFields: NewFieldSet("age"),
+	}, WithVersioning[TestUser, uint]("users"))
+	t601 := WithTenantID(ctx, uint(601))
+	own := TestUser{Name: "Own", Email: "versions-tenant-1@example.com"}
+	if err = tenanted.Create(t601, &own); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, own.ID)
+	foreign := TestUser{Name: "Foreign", Email: "versions-tenant-2@example.com", UserID: 602}
+	db.Create(&foreign)
+	defer db.Unscoped().Delete(&TestUser{}, foreign.ID)
+	db.Create(&RecordVersion{Resource: "users", RecordID: fmt.Sprint(own.ID), Version: 7, TenantID: "602"})
+	if _, err = tenanted.UpdateMany(t601, []uint{own.ID, foreign.ID}, map[string]any{"age": 9}); err != nil {
+		t.Fatal(err)
+	}
+	if vs, err = tenanted.Versions(t601, own.ID); err != nil || len(vs) != 2 {
+		t.Fatalf("own: %d versions, %v", len(vs), err)
+	}
+	assert.Equal(t, 2, vs[1].Version)
+	assert.Equal(t, 9, vs[1].Data.Age)
+	var foreignVersions int64
+	db.Model(&RecordVersion{}).Where("resource = ? AND record_id = ?", "users", fmt.Sprint(foreign.ID)).Count(&foreignVersions)
+	assert.Equal(t, int64(0), foreignVersions)
+
+	// историю жёстко удалённой записи тенантный репозиторий не отдаёт: владельца уже не проверить
+	db.Unscoped().Delete(&TestUser{}, own.ID)
+	if _, err = tenanted.Versions(t601, own.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCachedRepo(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	inner := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{"role": NewFieldSet("eq")},
+	})
+	repo := NewCachedRepo[TestUser, uint](inner, NewLRUCache(100), CacheOptions{CacheLists: true})
+
+	a := TestUser{Name: "Cached A", Email: "cached-a@example.com", Role: "cached"}
+	b := TestUser{Name: "Cached B", Email: "cached-b@example.com", Role: "cached"}
+	for _, u := range []*TestUser{&a, &b} {
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	}
+	if _, err := repo.GetOne(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	list := ListParams{Filters: []Filter{{Field: "role", Operator: "eq", Value: "cached"}}}
+	if _, total, err := repo.GetList(ctx, list); err != nil || total != 2 {
+		t.Fatalf("list: %d %v", total, err)
+	}
+
+	// изменение в обход декоратора не видно до сброса
+	db.Model(&TestUser{}).Where("id = ?", a.ID).Update("name", "Bypassed")
+	got, _ := repo.GetOne(ctx, a.ID)
+	assert.Equal(t, "Cached A", got.Name)
+	got, _ = repo.GetOne(WithTenantID(ctx, 1), a.ID) // другой тенант — другой ключ
+	assert.Equal(t, "Bypassed", got.Name)
+
+	res, err := repo.GetManyOrdered(ctx, []uint{b.ID, a.ID, 999999, a.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(res.Items))
+	assert.Equal(t, b.ID, res.Items[0].ID)
+	assert.Equal(t, "Cached A", res.Items[1].Name)
+	assert.Equal(t, []uint{999999}, res.Missing)
+
+	if _, err = repo.Update(ctx, a.ID, map[string]any{"name": "Updated"}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = repo.GetOne(ctx, a.ID)
+	assert.Equal(t, "Updated", got.Name)
+
+	if err = repo.Delete(ctx, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.GetOne(ctx, b.ID); err == nil {
+		t.Fatal("expected deleted record to miss cache")
+	}
+	reordered := ListParams{Filters: []Filter{{Field: "role", Operator: "EQ", Value: "cached"}}}
+	if _, total, _ := repo.GetList(ctx, reordered); total != 1 {
+		t.Fatalf("expected list to be invalidated, got %d", total)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Get("a")
+	c.Set("c", 3, 0) // вытесняет b
+	_, ok := c.Get("b")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, 2, c.Len())
+
+	c.Set("ttl", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	_, ok = c.Get("ttl")
+	assert.Equal(t, false, ok)
+}
+
+func TestGormRepo_Reader(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	replica, err := setupTestDB() // отдельная БД — «отставшая» реплика
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{}, WithReader[TestUser, uint](replica))
+
+	u := TestUser{Name: "Primary only", Email: "replica@example.com"}
+	if err = repo.Create(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+
+	if _, err = repo.GetOne(ctx, u.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected replica miss, got %v", err)
+	}
+	got, err := repo.GetOne(ReadPrimary(ctx), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Primary only", got.Name)
+
+	// Update отдаёт свежую запись из основной БД
+	got, err = repo.Update(ctx, u.ID, map[string]any{"name": "Updated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Updated", got.Name)
+
+	_ = NewTxManager(db).RunInTx(ctx, func(txCtx context.Context) error {
+		_, err := repo.GetOne(txCtx, u.ID)
+		assert.Equal(t, nil, err)
+		items, _ := repo.GetMany(txCtx, []uint{u.ID})
+		assert.Equal(t, 1, len(items))
+		return nil
+	})
+	if _, err = repo.WithTx(db).GetOne(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// политика проверяется по основной БД, а не по устаревшей копии на реплике
+	locked := TestUser{Name: "Locked", Email: "replica-locked@example.com", Role: "locked"}
+	if err = db.Create(&locked).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, locked.ID)
+	stale := locked
+	stale.Role = ""
+	if err = replica.Create(&stale).Error; err != nil {
+		t.Fatal(err)
+	}
+	guarded := NewGormRepo[TestUser, uint](db, RepoConfig{}, WithReader[TestUser, uint](replica), WithPolicy[TestUser, uint](lockedPolicy{}))
+	if _, err = guarded.DeleteMany(ctx, []uint{locked.ID}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if _, err = guarded.UpdateMany(ctx, []uint{locked.ID}, map[string]any{"name": "x"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestObservedRepo(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	var buf strings.Builder
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	repo, err := NewObservedRepo[TestUser, uint](NewGormRepo[TestUser, uint](db, RepoConfig{}), "users", ObserveOptions{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := TestUser{Name: "Observed", Email: "observed@example.com"}
+	if err = repo.Create(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	if _, err = repo.GetOne(ctx, 999999); ErrorClass(err) != ErrClassNotFound {
+		t.Fatalf("expected not_found, got %v", err)
+	}
+
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err = json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, m)
+	}
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "Create", lines[0]["op"])
+	assert.Equal(t, "DEBUG", lines[0]["level"])
+	assert.Equal(t, "users", lines[1]["resource"])
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, ErrClassNotFound, lines[1]["error.class"])
+
+	assert.Equal(t, ErrClassForbidden, ErrorClass(fmt.Errorf("%w: update 1", ErrForbidden)))
+	assert.Equal(t, ErrClassTimeout, ErrorClass(context.DeadlineExceeded))
+	assert.Equal(t, ErrClassInternal, ErrorClass(errors.New("boom")))
+
+	// значения фильтров и строка поиска в атрибуты не попадают
+	attrs := ListParamsAttributes(ListParams{Filters: []Filter{{Field: "email", Operator: "EQ", Value: "secret@example.com"}}, Search: "secret"})
+	for _, a := range attrs {
+		if strings.Contains(a.Value.Emit(), "secret") {
+			t.Fatalf("attribute %s leaks value: %s", a.Key, a.Value.Emit())
+		}
+	}
+	assert.Equal(t, []string{"email:eq"}, attrs[0].Value.AsStringSlice())
+}
+
+func TestGormRepo_CostGuards(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB)
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedFilterOps: map[string]FieldSet{
+			"email": NewFieldSet("eq", "icontains", "startswith"),
+			"age":   NewFieldSet("in", "gte"),
+		},
+		AllowedSearchFields: NewFieldSet("name", "email"),
+		MaxFilters:          2,
+		MaxSearchFields:     1,
+		MaxInValues:         3,
+		NoLeadingWildcard:   NewFieldSet("email"),
+		MaxPageSize:         2,
+	})
+	for i := 0; i < 3; i++ {
+		u := TestUser{Name: fmt.Sprintf("Guard %d", i), Email: fmt.Sprintf("guard%d@example.com", i), Age: 70 + i}
+		if err := repo.Create(ctx, &u); err != nil {
+			t.Fatal(err)
+		}
+		defer db.Unscoped().Delete(&TestUser{}, u.ID)
+	}
+
+	expensive := []ListParams{
+		{Filters: []Filter{{Field: "age", Operator: "gte", Value: 1}, {Field: "age", Operator: "gte", Value: 2}, {Field: "age", Operator: "gte", Value: 3}}},
+		{Filters: []Filter{{Field: "age", Operator: "in", Value: []int{1, 2, 3, 4}}}},
+		{Filters: []Filter{{Field: "email", Operator: "icontains", Value: "guard"}}},
+		{Filters: []Filter{{Field: "email", Operator: "startswith", Value: "%guard"}}},
+		{Search: "guard", SearchFields: []string{"name", "email"}},
+	}
+	for i, p := range expensive {
+		if _, _, err := repo.GetList(ctx, p); !errors.Is(err, ErrQueryTooExpensive) {
+			t.Fatalf("case %d: expected ErrQueryTooExpensive, got %v", i, err)
+		}
+	}
+
+	items, total, err := repo.GetList(ctx, ListParams{
+		Filters:    []Filter{{Field: "email", Operator: "startswith", Value: "guard"}},
+		Pagination: Pagination{Page: 1, PerPage: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, 2, len(items)) // MaxPageSize
+
+	// поиск по email — только по префиксу
+	_, total, _ = repo.GetList(ctx, ListParams{Search: "example", SearchFields: []string{"email"}})
+	assert.Equal(t, int64(0), total)
+	_, total, _ = repo.GetList(ctx, ListParams{Search: "guard1", SearchFields: []string{"email"}})
+	assert.Equal(t, int64(1), total)
+
+	slow := NewGormRepo[TestUser, uint](db, RepoConfig{Timeouts: QueryTimeouts{Get: time.Nanosecond}})
+	if _, err = slow.Count(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestSearchers_SQL(t *testing.T) {
+	db := ctx.Value("db").(*gorm.DB).Session(&gorm.Session{DryRun: true})
+	sqlOf := func(s Searcher, rank bool) string {
+		q, err := s.Search(db.Model(&TestUser{}), SearchRequest{
+			Table: "test_users", PK: "id", Fields: []string{"name", "email"}, Query: "price list", Rank: rank,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []TestUser
+		stmt := q.Find(&out).Statement
+		return stmt.SQL.String()
+	}
+
+	pg := sqlOf(PostgresSearcher{Config: "english"}, true)
+	assert.Equal(t, true, strings.Contains(pg, "@@ websearch_to_tsquery(?::regconfig, ?)"))
+	assert.Equal(t, true, strings.Contains(pg, "ORDER BY ts_rank(to_tsvector(?::regconfig, coalesce(name::text, '') || ' ' || coalesce(email::text, ''))"))
+	pg = sqlOf(PostgresSearcher{Column: "search"}, false)
+	assert.Equal(t, true, strings.Contains(pg, "search @@ websearch_to_tsquery"))
+	assert.Equal(t, false, strings.Contains(pg, "ORDER BY"))
+
+	my := sqlOf(MySQLSearcher{}, true)
+	assert.Equal(t, true, strings.Contains(my, "MATCH(name, email) AGAINST(? IN NATURAL LANGUAGE MODE)"))
+	assert.Equal(t, true, strings.Contains(my, "ORDER BY MATCH(name, email) AGAINST(? IN NATURAL LANGUAGE MODE) DESC"))
+
+	assert.Equal(t, `{name email} : "price" "list"`, fts5Query([]string{"name", "email"}, "price list"))
+	assert.Equal(t, `{name} : "a""b"`, fts5Query([]string{"name"}, `a"b`))
+}
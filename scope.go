@@ -0,0 +1,114 @@
+package axcrud
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContextScope — скоуп с доступом к ctx запроса. Ошибка (например, нет пользователя в ctx)
+// прерывает операцию, а не приводит к панике.
+type ContextScope func(ctx context.Context, db *gorm.DB) (*gorm.DB, error)
+
+// Principal — кто выполняет запрос
+type Principal struct {
+	ID    any
+	Roles []string
+}
+
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+type tenantKey struct{}
+type principalKey struct{}
+
+func WithTenantID(ctx context.Context, tenantID any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func TenantIDFromContext(ctx context.Context) (any, bool) {
+	v := ctx.Value(tenantKey{})
+	return v, v != nil
+}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// applyContextScopes — ContextScopes и фильтр по RepoConfig.TenantColumn.
+// Ошибка кладётся в сам *gorm.DB (AddError), и запрос не выполняется.
+func (r *GormRepo[T, ID]) applyContextScopes(ctx context.Context, q *gorm.DB) *gorm.DB {
+	for _, s := range r.cfg.ContextScopes {
+		nq, err := s(ctx, q)
+		if err != nil {
+			_ = q.AddError(err)
+			return q
+		}
+		q = nq
+	}
+	if col := r.cfg.TenantColumn; col != "" {
+		tenantID, ok := TenantIDFromContext(ctx)
+		if !ok {
+			_ = q.AddError(ErrNoTenant)
+			return q
+		}
+		q = q.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: col}, Value: tenantID})
+	}
+	return q
+}
+
+// stampTenant проставляет тенанта из ctx в запись перед вставкой/сохранением
+func (r *GormRepo[T, ID]) stampTenant(ctx context.Context, obj *T) error {
+	col := r.cfg.TenantColumn
+	if col == "" {
+		return nil
+	}
+	tenantID, ok := TenantIDFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	if r.schema == nil {
+		return fmt.Errorf("model schema is not available")
+	}
+	field := r.schema.LookUpField(col)
+	if field == nil {
+		return fmt.Errorf("tenant column '%s' not found", col)
+	}
+	return field.Set(ctx, reflect.ValueOf(obj), tenantID)
+}
+
+func (r *GormRepo[T, ID]) stampTenantAll(ctx context.Context, items []T) error {
+	for i := range items {
+		if err := r.stampTenant(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTenantKey — ключ patch указывает на колонку тенанта (по имени колонки или поля)
+func (r *GormRepo[T, ID]) isTenantKey(key string) bool {
+	col := r.cfg.TenantColumn
+	if col == "" {
+		return false
+	}
+	if key == col {
+		return true
+	}
+	if r.schema != nil {
+		if f := r.schema.LookUpField(key); f != nil && f.DBName == col {
+			return true
+		}
+	}
+	return false
+}
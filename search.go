@@ -0,0 +1,163 @@
+package axcrud
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchRequest — поиск ListParams.Search по уже проверенным полям (AllowedSearchFields, FieldAccess)
+type SearchRequest struct {
+	Table  string   // таблица модели
+	PK     string   // колонка первичного ключа
+	Fields []string // колонки для поиска
+	Query  string
+	// Сортировать по релевантности: в запросе нет явной сортировки (только GetList)
+	Rank bool
+	// Поля, по которым нельзя искать с ведущим % (RepoConfig.NoLeadingWildcard)
+	PrefixOnly FieldSet
+}
+
+// Searcher добавляет к запросу условие поиска (и ORDER BY по релевантности при req.Rank).
+// Задаётся в RepoConfig.Searcher; по умолчанию LikeSearcher.
+type Searcher interface {
+	Search(db *gorm.DB, req SearchRequest) (*gorm.DB, error)
+}
+
+// LikeSearcher — OR по полям с LOWER(col) LIKE LOWER('%q%'); работает везде, но без индексов и релевантности
+type LikeSearcher struct{}
+
+func (LikeSearcher) Search(db *gorm.DB, req SearchRequest) (*gorm.DB, error) {
+	like := "%" + req.Query + "%"
+	conds := make([]string, 0, len(req.Fields))
+	args := make([]any, 0, len(req.Fields))
+
+	// Кросс-диалектная регистронезависимость:
+	// - MySQL/SQLite: LOWER() работает
+	// - Postgres: тоже ок
+	for _, f := range req.Fields {
+		conds = append(conds, fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", clause.Column{Name: f}.Name))
+		if req.PrefixOnly.Has(f) {
+			args = append(args, strings.TrimLeft(req.Query, "%_")+"%")
+			continue
+		}
+		args = append(args, like)
+	}
+
+	// (LOWER(f1) LIKE LOWER(?) OR LOWER(f2) LIKE LOWER(?) ...)
+	return db.Where("("+strings.Join(conds, " OR ")+")", args...), nil
+}
+
+// PostgresSearcher — to_tsvector(...) @@ websearch_to_tsquery(...), релевантность — ts_rank.
+// Для индекса задайте Column — generated-колонку tsvector с GIN-индексом, например:
+//
+//	ALTER TABLE docs ADD COLUMN search tsvector
+//	  GENERATED ALWAYS AS (to_tsvector('simple', coalesce(title,'') || ' ' || coalesce(body,''))) STORED;
+//	CREATE INDEX docs_search ON docs USING GIN (search);
+//
+// Без Column вектор строится на лету из полей запроса.
+type PostgresSearcher struct {
+	Config string // конфигурация текстового поиска ("simple", "english", "russian"); по умолчанию "simple"
+	Column string
+}
+
+func (s PostgresSearcher) Search(db *gorm.DB, req SearchRequest) (*gorm.DB, error) {
+	cfg := s.Config
+	if cfg == "" {
+		cfg = "simple"
+	}
+	var vector string
+	var vars []any
+	if s.Column != "" {
+		vector = clause.Column{Name: s.Column}.Name
+	} else {
+		parts := make([]string, len(req.Fields))
+		for i, f := range req.Fields {
+			parts[i] = fmt.Sprintf("coalesce(%s::text, '')", clause.Column{Name: f}.Name)
+		}
+		vector = "to_tsvector(?::regconfig, " + strings.Join(parts, " || ' ' || ") + ")"
+		vars = append(vars, cfg)
+	}
+	query := "websearch_to_tsquery(?::regconfig, ?)"
+	db = db.Where(vector+" @@ "+query, append(append([]any{}, vars...), cfg, req.Query)...)
+	if req.Rank {
+		db = db.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "ts_rank(" + vector + ", " + query + ") DESC",
+			Vars:               append(append([]any{}, vars...), cfg, req.Query),
+			WithoutParentheses: true,
+		}})
+	}
+	return db, nil
+}
+
+// SQLiteFTS5Searcher — поиск через виртуальную таблицу FTS5, у которой rowid = PK модели:
+//
+//	CREATE VIRTUAL TABLE docs_fts USING fts5(title, body, content='docs', content_rowid='id');
+//
+// (плюс триггеры синхронизации или внешнее наполнение). Колонки FTS-таблицы должны называться как поля поиска.
+// Слова запроса ищутся целиком и через AND; релевантность — bm25.
+// В mattn/go-sqlite3 FTS5 включается тегом сборки sqlite_fts5.
+type SQLiteFTS5Searcher struct {
+	Table string // FTS5-таблица
+}
+
+func (s SQLiteFTS5Searcher) Search(db *gorm.DB, req SearchRequest) (*gorm.DB, error) {
+	if s.Table == "" {
+		return db, fmt.Errorf("fts5 table is not set")
+	}
+	match := fts5Query(req.Fields, req.Query)
+	if match == "" {
+		return db, nil
+	}
+	pk := req.PK
+	if req.Table != "" {
+		pk = req.Table + "." + req.PK
+	}
+	fts := s.Table
+	db = db.Where(fmt.Sprintf("%s IN (SELECT rowid FROM %s WHERE %s MATCH ?)", pk, fts, fts), match)
+	if req.Rank {
+		db = db.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                fmt.Sprintf("(SELECT bm25(%s) FROM %s WHERE %s MATCH ? AND rowid = %s)", fts, fts, fts, pk),
+			Vars:               []any{match},
+			WithoutParentheses: true,
+		}})
+	}
+	return db, nil
+}
+
+// fts5Query — {f1 f2} : "w1" "w2"; слова в кавычках, чтобы операторы FTS5 из ввода не работали
+func fts5Query(fields []string, q string) string {
+	words := strings.Fields(q)
+	if len(words) == 0 {
+		return ""
+	}
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return "{" + strings.Join(fields, " ") + "} : " + strings.Join(words, " ")
+}
+
+// MySQLSearcher — MATCH(fields) AGAINST(q); нужен FULLTEXT-индекс ровно по этим колонкам
+// (поэтому SearchFields в запросе должны совпадать с индексом). Релевантность — значение MATCH.
+type MySQLSearcher struct {
+	BooleanMode bool // IN BOOLEAN MODE вместо NATURAL LANGUAGE MODE
+}
+
+func (s MySQLSearcher) Search(db *gorm.DB, req SearchRequest) (*gorm.DB, error) {
+	cols := make([]string, len(req.Fields))
+	for i, f := range req.Fields {
+		cols[i] = clause.Column{Name: f}.Name
+	}
+	mode := "IN NATURAL LANGUAGE MODE"
+	if s.BooleanMode {
+		mode = "IN BOOLEAN MODE"
+	}
+	expr := fmt.Sprintf("MATCH(%s) AGAINST(? %s)", strings.Join(cols, ", "), mode)
+	db = db.Where(expr, req.Query)
+	if req.Rank {
+		db = db.Order(clause.OrderBy{Expression: clause.Expr{SQL: expr + " DESC", Vars: []any{req.Query}, WithoutParentheses: true}})
+	}
+	return db, nil
+}
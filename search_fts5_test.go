@@ -0,0 +1,59 @@
+//go:build sqlite_fts5
+
+package axcrud
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// go test -tags sqlite_fts5 -run FTS5 .
+func TestGormRepo_SearchSQLiteFTS5(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE test_users_fts USING fts5(name, email, content='test_users', content_rowid='id')`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewGormRepo[TestUser, uint](db, RepoConfig{
+		AllowedSearchFields: NewFieldSet("name", "email"),
+		AllowedSortFields:   NewFieldSet("id"),
+		Searcher:            SQLiteFTS5Searcher{Table: "test_users_fts"},
+	})
+	users := []TestUser{
+		{Name: "Price list", Email: "a@example.com"},
+		{Name: "Contract price price", Email: "b@example.com"},
+		{Name: "Priceless", Email: "c@example.com"},
+	}
+	if _, err = repo.CreateMany(ctx, users, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Exec(`INSERT INTO test_users_fts(rowid, name, email) SELECT id, name, email FROM test_users`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// целые слова: "Priceless" не находится; релевантнее — где слово встречается чаще
+	items, total, err := repo.GetList(ctx, ListParams{Search: "price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, "Contract price price", items[0].Name)
+
+	// явная сортировка отменяет сортировку по релевантности
+	items, _, err = repo.GetList(ctx, ListParams{Search: "price", Sort: &Sort{Field: "id", Order: "asc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Price list", items[0].Name)
+
+	// операторы FTS5 из ввода экранируются
+	_, total, err = repo.GetList(ctx, ListParams{Search: `price OR "a`, SearchFields: []string{"name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), total)
+}
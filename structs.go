@@ -2,6 +2,7 @@ package axcrud
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -13,14 +14,49 @@ type IDConstraint interface {
 type Repo[T any, ID IDConstraint] interface {
 	GetList(ctx context.Context, p ListParams) (items []T, total int64, err error)
 	GetOne(ctx context.Context, id ID) (T, error)
+	// Поиск по фильтрам (натуральные ключи: email, slug и т.п.); ErrNotFound, если записи нет
+	FindOne(ctx context.Context, filters []Filter) (T, error)
+	CountWhere(ctx context.Context, p ListParams) (int64, error)
+	Exists(ctx context.Context, filters []Filter) (bool, error)
 	Create(ctx context.Context, in *T) error
+	// Пакетная вставка; возвращает ID созданных записей в порядке items
+	CreateMany(ctx context.Context, items []T, batchSize int) ([]ID, error)
+	// INSERT ... ON CONFLICT DO UPDATE
+	Upsert(ctx context.Context, in *T, p UpsertParams) error
+	UpsertMany(ctx context.Context, items []T, p UpsertParams) ([]ID, error)
 	Update(ctx context.Context, id ID, patch map[string]any) (T, error)
+	// Массовое обновление; возвращают число затронутых строк
+	UpdateMany(ctx context.Context, ids []ID, patch map[string]any) (affected int64, err error)
+	UpdateWhere(ctx context.Context, filters []Filter, patch map[string]any) (affected int64, err error)
 	Delete(ctx context.Context, id ID) error
 	DeleteMany(ctx context.Context, ids []ID) (affected int64, err error)
+	DeleteWhere(ctx context.Context, filters []Filter, opts DeleteWhereOptions) (affected int64, err error)
 	Save(ctx context.Context, id ID, obj T) (T, error)
+	// count/sum/avg/min/max с группировкой по тем же фильтрам, что и GetList
+	Aggregate(ctx context.Context, p AggregateParams) ([]AggregateRow, error)
+	// distinct-значения с количеством для фильтров-дропдаунов
+	Facets(ctx context.Context, p ListParams, fields []string) (map[string][]FacetValue, error)
+	// Обход всех записей по фильтрам пачками (keyset по PK); возвращает число обработанных
+	Iterate(ctx context.Context, p ListParams, batchSize int, fn func(batch []T) error) (processed int64, err error)
 	// Транзакции опционально
 	WithTx(tx *gorm.DB) Repo[T, ID]
 	GetMany(context.Context, []ID) ([]T, error)
+	// GetMany с порядком как в запросе, без дублей и со списком ненайденных ID
+	GetManyOrdered(ctx context.Context, ids []ID) (ManyResult[T, ID], error)
+}
+
+// VersionedRepo — история записей (GormRepo с WithVersioning)
+type VersionedRepo[T any, ID IDConstraint] interface {
+	Versions(ctx context.Context, id ID) ([]Version[T], error)
+	// Состояние на момент времени; ErrNotFound, если записи не было или она удалена
+	AsOf(ctx context.Context, id ID, at time.Time) (T, error)
+	Diff(ctx context.Context, id ID, from, to int) ([]FieldChange, error)
+	Revert(ctx context.Context, id ID, version int) (T, error)
+}
+
+type ManyResult[T any, ID IDConstraint] struct {
+	Items   []T
+	Missing []ID
 }
 
 type Filter struct {
@@ -46,3 +82,11 @@ type ListParams struct {
 	SearchFields []string // по каким полям делать поисковый OR ... LIKE
 	Pagination   Pagination
 }
+
+type UpsertParams struct {
+	// Колонки уникального ключа (ON CONFLICT (...)); пусто — RepoConfig.UpsertConflictColumns
+	ConflictColumns []string
+	// Колонки, обновляемые при конфликте; пусто — RepoConfig.UpsertUpdateColumns, иначе все, кроме PK
+	UpdateColumns []string
+	BatchSize     int
+}
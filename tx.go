@@ -0,0 +1,79 @@
+package axcrud
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+type afterCommitKey struct{}
+
+// afterCommitHooks — колбэки, отложенные до commit; у каждого уровня RunInTx свой список
+type afterCommitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+func (h *afterCommitHooks) add(fns ...func()) {
+	h.mu.Lock()
+	h.fns = append(h.fns, fns...)
+	h.mu.Unlock()
+}
+
+// AfterCommit откладывает fn до commit внешней транзакции RunInTx; при rollback fn не вызывается.
+// Вне RunInTx fn выполняется сразу.
+func AfterCommit(ctx context.Context, fn func()) {
+	if h, ok := ctx.Value(afterCommitKey{}).(*afterCommitHooks); ok {
+		h.add(fn)
+		return
+	}
+	fn()
+}
+
+// ContextWithTx кладёт транзакцию в контекст: все GormRepo, вызванные с этим ctx, работают внутри неё.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// TxManager — транзакции поверх контекста, общие для нескольких репозиториев одной БД.
+type TxManager struct {
+	db *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// RunInTx выполняет fn в транзакции: commit, если fn вернула nil, иначе rollback.
+// Если в ctx уже есть транзакция — вложенный вызов идёт через SAVEPOINT
+// и при ошибке откатывает только свою часть.
+func (m *TxManager) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	db := m.db
+	if tx, ok := TxFromContext(ctx); ok {
+		db = tx
+	}
+	parent, nested := ctx.Value(afterCommitKey{}).(*afterCommitHooks)
+	hooks := &afterCommitHooks{}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ContextWithTx(ctx, tx), afterCommitKey{}, hooks))
+	})
+	if err != nil {
+		return err
+	}
+	if nested {
+		// SAVEPOINT отпущен, но commit ещё впереди
+		parent.add(hooks.fns...)
+		return nil
+	}
+	for _, f := range hooks.fns {
+		f()
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package axcrud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordVersion — полный снимок строки после изменения (таблица axcrud_versions, общая для ресурсов)
+type RecordVersion struct {
+	ID        uint64          `gorm:"primaryKey"`
+	Resource  string          `gorm:"size:128;uniqueIndex:idx_axcrud_record_version,priority:1"`
+	RecordID  string          `gorm:"size:128;uniqueIndex:idx_axcrud_record_version,priority:3"`
+	Version   int             `gorm:"uniqueIndex:idx_axcrud_record_version,priority:4"`
+	Op        EventType       `gorm:"size:32"`
+	Data      json.RawMessage // JSON записи; у deleted — последнее известное состояние
+	ChangedBy string          `gorm:"size:128"`
+	TenantID  string          `gorm:"size:128;index;uniqueIndex:idx_axcrud_record_version,priority:2"`
+	CreatedAt time.Time       `gorm:"index"`
+}
+
+func (RecordVersion) TableName() string {
+	return "axcrud_versions"
+}
+
+// MigrateVersions создаёт/обновляет таблицу axcrud_versions.
+// Старый уникальный индекс без тенанта (idx_axcrud_version) удаляется: нумерация версий идёт в пределах тенанта.
+func MigrateVersions(db *gorm.DB) error {
+	if err := db.AutoMigrate(&RecordVersion{}); err != nil {
+		return err
+	}
+	if m := db.Migrator(); m.HasIndex(&RecordVersion{}, "idx_axcrud_version") {
+		return m.DropIndex(&RecordVersion{}, "idx_axcrud_version")
+	}
+	return nil
+}
+
+// Version — версия записи для API
+type Version[T any] struct {
+	Version   int       `json:"version"`
+	Op        EventType `json:"op"`
+	Data      *T        `json:"data,omitempty"`
+	ChangedBy string    `json:"changedBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FieldChange — различие одного поля между двумя версиями
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// WithVersioning — опция NewGormRepo: после каждого изменения в той же транзакции
+// сохраняется полная копия строки (JSON модели) в axcrud_versions. Таблица — MigrateVersions.
+func WithVersioning[T any, ID IDConstraint](resource string) func(*GormRepo[T, ID]) {
+	return func(r *GormRepo[T, ID]) {
+		r.versions = true
+		r.resource = resource
+	}
+}
+
+// matchingIDs — ID строк под запросом до записи: версии и события получают только реально
+// затронутые строки, а не все запрошенные ID
+func (r *GormRepo[T, ID]) matchingIDs(q *gorm.DB) ([]ID, error) {
+	if !r.tracksChanges() {
+		return nil, nil
+	}
+	var ids []ID
+	err := q.Session(&gorm.Session{}).Pluck(r.idCol, &ids).Error
+	return ids, err
+}
+
+// rowLockingSupported — диалект понимает SELECT ... FOR UPDATE.
+// SQLite его не знает, но и не нуждается: пишущая транзакция там одна на всю БД.
+func (r *GormRepo[T, ID]) rowLockingSupported() bool {
+	switch r.db.Dialector.Name() {
+	case "postgres", "mysql":
+		return true
+	}
+	return false
+}
+
+// snapshot пишет версии для ids: строки перечитываются целиком в пределах Scopes и тенанта
+// (мягко удалённые тоже, без Restrict и маскирования полей)
+func (r *GormRepo[T, ID]) snapshot(ctx context.Context, op EventType, ids []ID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	db := r.conn(ctx)
+	// строки блокируются до конца транзакции: параллельный snapshot той же записи ждёт,
+	// а не вычисляет тот же номер версии
+	lock := func(q *gorm.DB) *gorm.DB {
+		if r.rowLockingSupported() {
+			return q.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		return q
+	}
+	var rows []T
+	err := lock(r.scoped(ctx, db).Unscoped()).
+		Where(clause.IN{Column: clause.Column{Name: r.idCol}, Values: toAnySlice(ids)}).
+		Find(&rows).Error
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]T, len(rows))
+	for i := range rows {
+		id, err := r.idOf(ctx, &rows[i])
+		if err != nil {
+			return err
+		}
+		byID[fmt.Sprint(id)] = rows[i]
+	}
+
+	var changedBy, tenant string
+	if p, ok := PrincipalFromContext(ctx); ok && p.ID != nil {
+		changedBy = fmt.Sprint(p.ID)
+	}
+	if t, ok := TenantIDFromContext(ctx); ok {
+		tenant = fmt.Sprint(t)
+	}
+	now := time.Now()
+	for _, id := range ids {
+		key := fmt.Sprint(id)
+		var last RecordVersion
+		// жёстко удалённую запись заблокировать нельзя — блокируем её последнюю версию
+		q := db.Where("resource = ? AND record_id = ?", r.resource, key)
+		if r.cfg.TenantColumn != "" {
+			q = q.Where("tenant_id = ?", tenant)
+		}
+		err := lock(q).Order("version DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+		v := RecordVersion{
+			Resource:  r.resource,
+			RecordID:  key,
+			Version:   last.Version + 1,
+			Op:        op,
+			Data:      last.Data, // жёстко удалённой строки уже нет
+			ChangedBy: changedBy,
+			TenantID:  tenant,
+			CreatedAt: now,
+		}
+		if row, ok := byID[key]; ok {
+			if v.Data, err = json.Marshal(row); err != nil {
+				return err
+			}
+		}
+		if err = db.Create(&v).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Versions — история записи от первой версии к последней.
+// Доступ — как к самой записи (checkHistoryAccess) и по тенанту версии, поля маскируются по FieldAccess.
+func (r *GormRepo[T, ID]) Versions(ctx context.Context, id ID) ([]Version[T], error) {
+	rows, err := r.versionRows(ctx, id, func(db *gorm.DB) *gorm.DB { return db })
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeVersions(ctx, id, rows)
+}
+
+// AsOf — состояние записи на момент at; если к тому моменту её не было или она удалена — ErrNotFound
+func (r *GormRepo[T, ID]) AsOf(ctx context.Context, id ID, at time.Time) (T, error) {
+	var out T
+	rows, err := r.versionRows(ctx, id, func(db *gorm.DB) *gorm.DB { return db.Where("created_at <= ?", at) })
+	if err != nil {
+		return out, err
+	}
+	vs, err := r.decodeVersions(ctx, id, rows)
+	if err != nil {
+		return out, err
+	}
+	if len(vs) == 0 || vs[len(vs)-1].Op == EventDeleted || vs[len(vs)-1].Data == nil {
+		return out, fmt.Errorf("%w: %v as of %s", ErrNotFound, id, at.Format(time.RFC3339))
+	}
+	return *vs[len(vs)-1].Data, nil
+}
+
+// Diff — поля (по JSON-именам), отличающиеся между версиями from и to
+func (r *GormRepo[T, ID]) Diff(ctx context.Context, id ID, from, to int) ([]FieldChange, error) {
+	a, err := r.versionData(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.versionData(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var out []FieldChange
+	for _, k := range keys {
+		if !reflect.DeepEqual(a[k], b[k]) {
+			out = append(out, FieldChange{Field: k, From: a[k], To: b[k]})
+		}
+	}
+	return out, nil
+}
+
+// Revert перезаписывает запись данными версии через Save (со всеми проверками) — появляется новая версия.
+// Жёстко удалённая запись создаётся заново; мягко удалённую восстановить так нельзя.
+func (r *GormRepo[T, ID]) Revert(ctx context.Context, id ID, version int) (T, error) {
+	var out T
+	vs, err := r.Versions(ctx, id)
+	if err != nil {
+		return out, err
+	}
+	var target *T
+	for _, v := range vs {
+		if v.Version == version {
+			target = v.Data
+		}
+	}
+	if target == nil {
+		return out, fmt.Errorf("%w: version %d of %v", ErrNotFound, version, id)
+	}
+	_, err = r.GetOne(ReadPrimary(ctx), id)
+	switch {
+	case err == nil:
+		return r.Save(ctx, id, *target)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		obj := *target
+		if err = r.Create(ctx, &obj); err != nil {
+			return out, err
+		}
+		return obj, nil
+	default:
+		return out, err
+	}
+}
+
+func (r *GormRepo[T, ID]) versionRows(ctx context.Context, id ID, where func(*gorm.DB) *gorm.DB) ([]RecordVersion, error) {
+	if !r.versions {
+		return nil, ErrVersioningDisabled
+	}
+	if err := r.checkHistoryAccess(ctx, id); err != nil {
+		return nil, err
+	}
+	q := r.conn(ctx).Where("resource = ? AND record_id = ?", r.resource, fmt.Sprint(id))
+	if r.cfg.TenantColumn != "" {
+		tenant, ok := TenantIDFromContext(ctx)
+		if !ok {
+			return nil, ErrNoTenant
+		}
+		q = q.Where("tenant_id = ?", fmt.Sprint(tenant))
+	}
+	var rows []RecordVersion
+	err := where(q).Order("version").Find(&rows).Error
+	return rows, err
+}
+
+// checkHistoryAccess — история доступна тому, кто может прочитать саму запись: Scopes, ContextScopes
+// и Restrict(OpRead) (мягко удалённая тоже подходит), затем CanRead.
+// Для жёстко удалённой записи проверять нечего — её историю отдаём только репозиториям без построчных ограничений
+// (Scopes, ContextScopes, TenantColumn, Policy).
+func (r *GormRepo[T, ID]) checkHistoryAccess(ctx context.Context, id ID) error {
+	byID := clause.Eq{Column: clause.Column{Name: r.idCol}, Value: id}
+	var rows []T
+	if err := r.readBase(ReadPrimary(ctx)).Unscoped().Where(byID).Limit(1).Find(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		if !r.can(ctx, OpRead, rows[0]) {
+			return fmt.Errorf("%w: %s %v", ErrForbidden, OpRead, id)
+		}
+		return nil
+	}
+	var exists int64
+	if err := r.conn(ctx).Unscoped().Model(new(T)).Where(byID).Count(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 || len(r.cfg.Scopes) > 0 || len(r.cfg.ContextScopes) > 0 || r.cfg.TenantColumn != "" || r.policy != nil {
+		return fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (r *GormRepo[T, ID]) decodeVersions(ctx context.Context, id ID, rows []RecordVersion) ([]Version[T], error) {
+	out := make([]Version[T], len(rows))
+	for i, row := range rows {
+		out[i] = Version[T]{Version: row.Version, Op: row.Op, ChangedBy: row.ChangedBy, CreatedAt: row.CreatedAt}
+		if len(row.Data) == 0 || string(row.Data) == "null" {
+			continue
+		}
+		var obj T
+		if err := json.Unmarshal(row.Data, &obj); err != nil {
+			return nil, err
+		}
+		out[i].Data = &obj
+	}
+	// право на чтение — по последнему известному состоянию
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Data != nil {
+			if !r.can(ctx, OpRead, *out[i].Data) {
+				return nil, fmt.Errorf("%w: %s %v", ErrForbidden, OpRead, id)
+			}
+			break
+		}
+	}
+	for i := range out {
+		if out[i].Data != nil {
+			r.maskFields(ctx, out[i].Data)
+		}
+	}
+	return out, nil
+}
+
+// versionData — данные версии как JSON-объект (после маскирования полей)
+func (r *GormRepo[T, ID]) versionData(ctx context.Context, id ID, version int) (map[string]any, error) {
+	vs, err := r.Versions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		if v.Version != version {
+			continue
+		}
+		if v.Data == nil {
+			return map[string]any{}, nil
+		}
+		raw, err := json.Marshal(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]any
+		err = json.Unmarshal(raw, &m)
+		return m, err
+	}
+	return nil, fmt.Errorf("%w: version %d of %v", ErrNotFound, version, id)
+}
+
+var _ VersionedRepo[struct{}, uint] = (*GormRepo[struct{}, uint])(nil)
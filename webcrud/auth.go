@@ -0,0 +1,41 @@
+package webcrud
+
+import (
+	"net/http"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalFn достаёт пользователя/роли из запроса (JWT, сессия, заголовок от gateway и т.п.).
+// ok=false — анонимный запрос: Principal в ctx не кладётся.
+type PrincipalFn func(req *http.Request) (p axcrud.Principal, tenantID any, ok bool)
+
+// ChiPrincipalMiddleware кладёт Principal (и тенанта, если он не nil) в ctx запроса —
+// от них зависят TenantColumn, Policy и FieldAccess репозиториев.
+func ChiPrincipalMiddleware(fn PrincipalFn) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, withPrincipal(req, fn))
+		})
+	}
+}
+
+func GinPrincipalMiddleware(fn PrincipalFn) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = withPrincipal(c.Request, fn)
+		c.Next()
+	}
+}
+
+func withPrincipal(req *http.Request, fn PrincipalFn) *http.Request {
+	p, tenantID, ok := fn(req)
+	if !ok {
+		return req
+	}
+	ctx := axcrud.WithPrincipal(req.Context(), p)
+	if tenantID != nil {
+		ctx = axcrud.WithTenantID(ctx, tenantID)
+	}
+	return req.WithContext(ctx)
+}
@@ -0,0 +1,186 @@
+package webcrud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/axgrid/axcrud"
+)
+
+const maxBatchOperations = 100
+
+// BatchOperation — одна операция POST /_batch.
+// Значения вида "$ref:<name>" в id и data заменяются на ID, созданный ранее операцией с ref=<name>.
+type BatchOperation struct {
+	Op       string          `json:"op"` // create | update | delete
+	Resource string          `json:"resource"`
+	ID       json.RawMessage `json:"id,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Ref      string          `json:"ref,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchResult struct {
+	Index int `json:"index"`
+	ID    any `json:"id,omitempty"`
+	Data  any `json:"data,omitempty"`
+}
+
+type BatchResponse struct {
+	Data  []BatchResult `json:"data,omitempty"`
+	Error string        `json:"error,omitempty"`
+	Index *int          `json:"index,omitempty"` // номер упавшей операции
+}
+
+type batchError struct {
+	index int
+	err   error
+}
+
+func (e *batchError) Error() string { return fmt.Sprintf("operation %d: %v", e.index, e.err) }
+func (e *batchError) Unwrap() error { return e.err }
+
+// runBatch выполняет операции по порядку в одной транзакции; при ошибке — rollback
+// и номер упавшей операции в ответе.
+func runBatch(ctx context.Context, tm *axcrud.TxManager, reg *Registry, in BatchRequest) (int, BatchResponse) {
+	if len(in.Operations) == 0 {
+		return http.StatusBadRequest, BatchResponse{Error: "operations required"}
+	}
+	if len(in.Operations) > maxBatchOperations {
+		return http.StatusBadRequest, BatchResponse{Error: fmt.Sprintf("too many operations (max %d)", maxBatchOperations)}
+	}
+
+	var results []BatchResult
+	err := tm.RunInTx(ctx, func(ctx context.Context) error {
+		results = make([]BatchResult, 0, len(in.Operations))
+		refs := map[string]any{}
+		for i, op := range in.Operations {
+			res, err := runBatchOp(ctx, reg, op, refs)
+			if err != nil {
+				return &batchError{index: i, err: err}
+			}
+			res.Index = i
+			if op.Ref != "" {
+				refs[op.Ref] = res.ID
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
+	if err != nil {
+		var be *batchError
+		if errors.As(err, &be) {
+			return errorStatus(be.err, http.StatusBadRequest), BatchResponse{Error: be.err.Error(), Index: &be.index}
+		}
+		return http.StatusInternalServerError, BatchResponse{Error: err.Error()}
+	}
+	return http.StatusOK, BatchResponse{Data: results}
+}
+
+func runBatchOp(ctx context.Context, reg *Registry, op BatchOperation, refs map[string]any) (BatchResult, error) {
+	res, err := reg.Get(op.Resource)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	data, err := resolveRefs(op.Data, refs)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	switch strings.ToLower(op.Op) {
+	case "create":
+		id, out, err := res.Create(ctx, data)
+		return BatchResult{ID: id, Data: out}, err
+	case "update", "delete":
+		id, err := batchID(op.ID, refs)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		if strings.EqualFold(op.Op, "delete") {
+			return BatchResult{ID: id}, res.Delete(ctx, id)
+		}
+		var patch map[string]any
+		if err = json.Unmarshal(data, &patch); err != nil {
+			return BatchResult{}, err
+		}
+		out, err := res.Update(ctx, id, patch)
+		return BatchResult{ID: id, Data: out}, err
+	default:
+		return BatchResult{}, fmt.Errorf("unsupported operation '%s'", op.Op)
+	}
+}
+
+// batchID — id операции строкой: число, строка или "$ref:<name>"
+func batchID(raw json.RawMessage, refs map[string]any) (string, error) {
+	if len(raw) == 0 {
+		return "", errors.New("id required")
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	v, err := substituteRefs(v, refs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v), nil
+}
+
+func resolveRefs(raw json.RawMessage, refs map[string]any) (json.RawMessage, error) {
+	if len(raw) == 0 || len(refs) == 0 {
+		return raw, nil
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := substituteRefs(v, refs)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func substituteRefs(v any, refs map[string]any) (any, error) {
+	switch t := v.(type) {
+	case string:
+		name, ok := strings.CutPrefix(t, "$ref:")
+		if !ok {
+			return t, nil
+		}
+		id, ok := refs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown ref '%s'", name)
+		}
+		return id, nil
+	case map[string]any:
+		for k, item := range t {
+			nv, err := substituteRefs(item, refs)
+			if err != nil {
+				return nil, err
+			}
+			t[k] = nv
+		}
+	case []any:
+		for i, item := range t {
+			nv, err := substituteRefs(item, refs)
+			if err != nil {
+				return nil, err
+			}
+			t[i] = nv
+		}
+	}
+	return v, nil
+}
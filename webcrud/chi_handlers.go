@@ -1,6 +1,7 @@
 package webcrud
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -14,7 +15,7 @@ func ChiGetList[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
 		lp := AdaptRefineList(rreq)
 		items, total, err := r.GetList(req.Context(), lp)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, ListResponse[T]{Data: items, Total: total})
@@ -25,13 +26,13 @@ func ChiPostList[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc
 	return func(w http.ResponseWriter, req *http.Request) {
 		var in RefineListRequest
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		lp := AdaptRefineList(in)
 		items, total, err := r.GetList(req.Context(), lp)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, ListResponse[T]{Data: items, Total: total})
@@ -42,28 +43,97 @@ func ChiCreate[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var in T
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		if err := r.Create(req.Context(), &in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, OneResponse[T]{Data: in})
 	}
 }
 
+// POST /resource/aggregate  {filters, search, metrics: [{fn, field, as}], groupBy: [], bucket: {field, unit}}
+func ChiAggregate[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var in RefineAggregateRequest
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		rows, err := r.Aggregate(req.Context(), AdaptRefineAggregate(in))
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, http.StatusOK, AggregateResponse{Data: rows})
+	}
+}
+
+// GET /resource/facets?fields[]=role&filters[...]&q=...
+func ChiFacets[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		fields := ParseFacetFields(req.URL.Query())
+		if len(fields) == 0 {
+			http.Error(w, "fields required", http.StatusBadRequest)
+			return
+		}
+		lp := AdaptRefineList(ParseRefineQuery(req.URL.Query()))
+		facets, err := r.Facets(req.Context(), lp, fields)
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, http.StatusOK, FacetsResponse{Data: facets})
+	}
+}
+
+// POST /resource/createMany  {"items": [...], "batchSize": 100}
+func ChiCreateMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var in bulkReq
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		status, out := runBulk(req.Context(), in, r.CreateMany)
+		WriteJSON(w, status, out)
+	}
+}
+
+// POST /resource/upsert  {"items": [...], "batchSize": 100, "conflictColumns": [...], "updateColumns": [...]};
+// колонки — из opts, по умолчанию RepoConfig.UpsertConflictColumns/UpsertUpdateColumns
+func ChiUpsert[T any, ID IDConstraint](r axcrud.Repo[T, ID], opts UpsertOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var in bulkReq
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		p, err := upsertParams(in, opts)
+		if err != nil {
+			WriteJSON(w, http.StatusBadRequest, BulkResponse[ID]{Error: err.Error()})
+			return
+		}
+		status, out := runBulk(req.Context(), in, func(ctx context.Context, items []T, _ int) ([]ID, error) {
+			return r.UpsertMany(ctx, items, p)
+		})
+		WriteJSON(w, status, out)
+	}
+}
+
 func ChiGetOne[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		idStr := chi.URLParam(req, "id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		item, err := r.GetOne(req.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, err, http.StatusNotFound)
 			return
 		}
 		WriteJSON(w, http.StatusOK, OneResponse[T]{Data: item})
@@ -85,34 +155,30 @@ func ChiGetMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
 			for _, s := range idsQ {
 				id, err := parseID[ID](s)
 				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
+					writeError(w, err, http.StatusBadRequest)
 					return
 				}
 				ids = append(ids, id)
 			}
-			items, err := r.GetMany(req.Context(), ids)
+			res, err := r.GetManyOrdered(req.Context(), ids)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				writeError(w, err, http.StatusBadRequest)
 				return
 			}
-			WriteJSON(w, http.StatusOK, struct {
-				Data []T `json:"data"`
-			}{Data: items})
+			WriteJSON(w, http.StatusOK, ManyResponse[T, ID]{Data: res.Items, Missing: res.Missing})
 			return
 		}
 		var in idsReq[ID]
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
-		items, err := r.GetMany(req.Context(), in.IDs)
+		res, err := r.GetManyOrdered(req.Context(), in.IDs)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
-		WriteJSON(w, http.StatusOK, struct {
-			Data []T `json:"data"`
-		}{Data: items})
+		WriteJSON(w, http.StatusOK, ManyResponse[T, ID]{Data: res.Items, Missing: res.Missing})
 	}
 }
 
@@ -121,33 +187,50 @@ func ChiUpdate[T any, ID ~uint | ~uint64 | ~int | ~int64 | ~string](r axcrud.Rep
 		idStr := chi.URLParam(req, "id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		var patch map[string]any
 		if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		item, err := r.Update(req.Context(), id, patch)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, OneResponse[T]{Data: item})
 	}
 }
 
+// PATCH /resource  и  POST /resource/updateMany
+func ChiUpdateMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var in updateManyReq[ID]
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		affected, err := runUpdateMany(req.Context(), r, in)
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, http.StatusOK, AffectedResponse{Data: affected})
+	}
+}
+
 func ChiDelete[T any, ID ~uint | ~uint64 | ~int | ~int64 | ~string](r axcrud.Repo[T, ID]) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		idStr := chi.URLParam(req, "id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		if err := r.Delete(req.Context(), id); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, AffectedResponse{Data: 1})
@@ -158,14 +241,27 @@ func ChiDeleteMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) http.HandlerFun
 	return func(w http.ResponseWriter, req *http.Request) {
 		var in idsReq[ID]
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		affected, err := r.DeleteMany(req.Context(), in.IDs)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, AffectedResponse{Data: affected})
 	}
 }
+
+// POST /_batch  {"operations": [{op, resource, id, data, ref}, ...]}
+func ChiBatch(tm *axcrud.TxManager, reg *Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var in BatchRequest
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		status, out := runBatch(req.Context(), tm, reg, in)
+		WriteJSON(w, status, out)
+	}
+}
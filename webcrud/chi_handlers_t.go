@@ -16,13 +16,13 @@ func ChiGetListT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Trans
 
 		items, total, err := r.GetList(req.Context(), lp)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		dtos, err := MapSlice(req.Context(), items, tr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -35,20 +35,20 @@ func ChiPostListT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Tran
 	return func(w http.ResponseWriter, req *http.Request) {
 		var in RefineListRequest
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		lp := AdaptRefineList(in)
 
 		items, total, err := r.GetList(req.Context(), lp)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		dtos, err := MapSlice(req.Context(), items, tr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -61,16 +61,16 @@ func ChiCreateT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Transf
 	return func(w http.ResponseWriter, req *http.Request) {
 		var in T
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		if err := r.Create(req.Context(), &in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		dto, err := tr(req.Context(), in)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, OneResponseDTO[DTO]{Data: dto})
@@ -83,19 +83,19 @@ func ChiGetOneT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Transf
 		idStr := chi.URLParam(req, "id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		item, err := r.GetOne(req.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, err, http.StatusNotFound)
 			return
 		}
 
 		dto, err := tr(req.Context(), item)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -108,7 +108,7 @@ func ChiGetManyT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Trans
 	return func(w http.ResponseWriter, req *http.Request) {
 		ids, ok, err := readIDsChi[ID](req)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		if !ok {
@@ -116,19 +116,19 @@ func ChiGetManyT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Trans
 			return
 		}
 
-		items, err := r.GetMany(req.Context(), ids)
+		res, err := r.GetManyOrdered(req.Context(), ids)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
-		dtos, err := MapSlice(req.Context(), items, tr)
+		dtos, err := MapSlice(req.Context(), res.Items, tr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
-		WriteJSON(w, http.StatusOK, ManyResponseDTO[DTO]{Data: dtos})
+		WriteJSON(w, http.StatusOK, ManyResponse[DTO, ID]{Data: dtos, Missing: res.Missing})
 	}
 }
 
@@ -138,25 +138,25 @@ func ChiUpdateT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Transf
 		idStr := chi.URLParam(req, "id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		var patch map[string]any
 		if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		item, err := r.Update(req.Context(), id, patch)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		dto, err := tr(req.Context(), item)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
@@ -170,12 +170,12 @@ func ChiDeleteT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID]) http.Hand
 		idStr := chi.URLParam(req, "id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 
 		if err := r.Delete(req.Context(), id); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, AffectedResponse{Data: 1})
@@ -187,18 +187,53 @@ func ChiDeleteManyT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID]) http.
 	return func(w http.ResponseWriter, req *http.Request) {
 		var in idsReq[ID]
 		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		affected, err := r.DeleteMany(req.Context(), in.IDs)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err, http.StatusBadRequest)
 			return
 		}
 		WriteJSON(w, http.StatusOK, AffectedResponse{Data: affected})
 	}
 }
 
+// GET /resource/export?format=csv|ndjson|xlsx&columns[]=...&filters[...]&q=...
+// Строки идут в порядке первичного ключа, sorters из запроса игнорируются.
+// Выгружает все подходящие записи (не одну страницу) потоково, через tr.
+func ChiExportT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr TransformFn[T, DTO], opts ExportOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		format, cols, err := prepareExport[DTO](req.URL.Query(), opts)
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		lp := AdaptRefineList(ParseRefineQuery(req.URL.Query()))
+		exportHeaders(w.Header(), format, opts)
+		w.WriteHeader(http.StatusOK)
+		if err = streamExport(req.Context(), w, format, cols, r, lp, tr, opts.BatchSize); err != nil {
+			// статус уже отправлен — обрываем ответ, чтобы клиент не принял обрезанный файл за целый
+			abortExport(w, err)
+		}
+	}
+}
+
+// POST /resource/import?format=csv|ndjson&mode=insert|upsert&dryRun=true
+// Файл — multipart-поле "file" или тело запроса; строки декодируются во входной DTO In и переводятся в T через from.
+// Без отдельного DTO (In == T) нужен ImportOptions.Columns, иначе паника при создании хендлера.
+func ChiImportT[T any, ID IDConstraint, In any](r axcrud.Repo[T, ID], from TransformFn[In, T], opts ImportOptions) http.HandlerFunc {
+	checkImportOptions[T, In](opts)
+	return func(w http.ResponseWriter, req *http.Request) {
+		status, report, err := runImport(req.Context(), req, r, from, opts)
+		if err != nil {
+			writeError(w, err, status)
+			return
+		}
+		WriteJSON(w, status, report)
+	}
+}
+
 // --- утилита чтения ids из GET/POST
 func readIDsChi[ID IDConstraint](req *http.Request) ([]ID, bool, error) {
 	// Если POST JSON — пробуем прочитать тело (не «съедаем» внешним декодером).
@@ -2,16 +2,67 @@
 package webcrud
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+
+	"github.com/axgrid/axcrud"
 )
 
 type idsReq[ID any] struct {
 	IDs []ID `json:"ids"`
 }
 
+// bulkReq — тело createMany/upsert: {"items": [...], "batchSize": 100};
+// у upsert ещё "conflictColumns" и "updateColumns" (только из UpsertOptions)
+type bulkReq struct {
+	Items           []json.RawMessage `json:"items"`
+	BatchSize       int               `json:"batchSize"`
+	ConflictColumns []string          `json:"conflictColumns"`
+	UpdateColumns   []string          `json:"updateColumns"`
+}
+
+// UpsertOptions — колонки, которые клиент может передать в conflictColumns/updateColumns.
+// Пусто — поле запроса запрещено, действуют RepoConfig.UpsertConflictColumns/UpsertUpdateColumns.
+type UpsertOptions struct {
+	ConflictColumns []string
+	UpdateColumns   []string
+}
+
+// upsertParams — UpsertParams из запроса; колонки вне UpsertOptions — ошибка
+func upsertParams(in bulkReq, opts UpsertOptions) (axcrud.UpsertParams, error) {
+	check := func(kind string, cols, allowed []string) error {
+		set := axcrud.NewFieldSet(allowed...)
+		for _, c := range cols {
+			if !set.Has(c) {
+				return fmt.Errorf("%s column '%s' is not allowed", kind, c)
+			}
+		}
+		return nil
+	}
+	if err := check("conflict", in.ConflictColumns, opts.ConflictColumns); err != nil {
+		return axcrud.UpsertParams{}, err
+	}
+	if err := check("update", in.UpdateColumns, opts.UpdateColumns); err != nil {
+		return axcrud.UpsertParams{}, err
+	}
+	return axcrud.UpsertParams{
+		ConflictColumns: in.ConflictColumns,
+		UpdateColumns:   in.UpdateColumns,
+		BatchSize:       in.BatchSize,
+	}, nil
+}
+
+// updateManyReq — тело updateMany: {"ids": [...], "variables": {...}} или {"filters": [...], "variables": {...}}
+type updateManyReq[ID any] struct {
+	IDs       []ID           `json:"ids"`
+	Filters   []RefineFilter `json:"filters"`
+	Variables map[string]any `json:"variables"`
+}
+
 type IDConstraint interface {
 	~uint | ~uint64 | ~int | ~int64 | ~string
 }
@@ -50,8 +101,70 @@ func parseID[ID IDConstraint](s string) (ID, error) {
 	}
 }
 
+// errorStatus — HTTP-статус для ошибки репозитория: типизированные ошибки важнее fallback
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, axcrud.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, axcrud.ErrNotFound), errors.Is(err, axcrud.ErrVersioningDisabled):
+		return http.StatusNotFound
+	}
+	return fallback
+}
+
+func writeError(w http.ResponseWriter, err error, fallback int) {
+	http.Error(w, err.Error(), errorStatus(err, fallback))
+}
+
 func WriteJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
+
+// runBulk — общая часть createMany/upsert для Chi и Gin.
+// Сначала декодируются все элементы: при ошибках ничего не пишется,
+// в ответе — ошибка по каждому невалидному элементу. Ошибка записи в БД — с номером пачки (batch).
+func runBulk[T any, ID IDConstraint](ctx context.Context, in bulkReq, write func(context.Context, []T, int) ([]ID, error)) (int, BulkResponse[ID]) {
+	if len(in.Items) == 0 {
+		return http.StatusBadRequest, BulkResponse[ID]{Error: "items required"}
+	}
+	items := make([]T, len(in.Items))
+	var failed []BulkItemResult[ID]
+	for i, raw := range in.Items {
+		if err := json.Unmarshal(raw, &items[i]); err != nil {
+			failed = append(failed, BulkItemResult[ID]{Index: i, Error: err.Error()})
+		}
+	}
+	if len(failed) > 0 {
+		return http.StatusBadRequest, BulkResponse[ID]{Data: failed, Error: "invalid items"}
+	}
+
+	ids, err := write(ctx, items, in.BatchSize)
+	if err != nil {
+		out := BulkResponse[ID]{Error: err.Error()}
+		var be *axcrud.BatchError
+		if errors.As(err, &be) {
+			out.Batch = &be.Index
+			out.Data = []BulkItemResult[ID]{{Index: be.Offset, Error: be.Err.Error()}}
+		}
+		return errorStatus(err, http.StatusBadRequest), out
+	}
+	out := BulkResponse[ID]{Data: make([]BulkItemResult[ID], len(ids)), Affected: int64(len(ids))}
+	for i := range ids {
+		out.Data[i] = BulkItemResult[ID]{Index: i, ID: &ids[i]}
+	}
+	return http.StatusOK, out
+}
+
+// runUpdateMany — по ids, если они есть, иначе по фильтрам
+func runUpdateMany[T any, ID IDConstraint](ctx context.Context, r axcrud.Repo[T, ID], in updateManyReq[ID]) (int64, error) {
+	if len(in.IDs) > 0 {
+		return r.UpdateMany(ctx, in.IDs, in.Variables)
+	}
+	if len(in.Filters) == 0 {
+		return 0, fmt.Errorf("ids or filters required")
+	}
+	lp := AdaptRefineList(RefineListRequest{Filters: in.Filters})
+	return r.UpdateWhere(ctx, lp.Filters, in.Variables)
+}
@@ -0,0 +1,139 @@
+package webcrud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+)
+
+const sseHeartbeat = 15 * time.Second
+
+// GET /resource/_events?types=created,updated&ids=1,2 — Server-Sent Events для refine liveProvider.
+// Переподключение: заголовок Last-Event-ID (или ?lastEventId=) — пропущенные события придут из буфера брокера.
+// События чужого тенанта (axcrud.TenantIDFromContext) не отправляются.
+func ChiEvents(b *axcrud.Broker, resource string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		serveEvents(req.Context(), w, req, b, resource)
+	}
+}
+
+func GinEvents(b *axcrud.Broker, resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serveEvents(ginCtx(c), c.Writer, c.Request, b, resource)
+	}
+}
+
+func serveEvents(ctx context.Context, w http.ResponseWriter, req *http.Request, b *axcrud.Broker, resource string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	lastID, err := lastEventID(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := eventFilter(ctx, req.URL.Query(), resource)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // nginx: не буферизовать поток
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := b.Subscribe(ctx, lastID, filter)
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				// отстали от потока — клиент переподключится с Last-Event-ID
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func lastEventID(req *http.Request) (uint64, error) {
+	s := req.Header.Get("Last-Event-ID")
+	if s == "" {
+		s = req.URL.Query().Get("lastEventId")
+	}
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Last-Event-ID: %s", s)
+	}
+	return id, nil
+}
+
+// eventFilter — ресурс, тенант подписчика и необязательные ?types= / ?ids= (через запятую).
+// События без IDs (например, опубликованные в Broker вручную) проходят фильтр ids: они могли затронуть любую запись.
+func eventFilter(ctx context.Context, q map[string][]string, resource string) func(axcrud.Event) bool {
+	tenantID, hasTenant := axcrud.TenantIDFromContext(ctx)
+	types := splitSet(q["types"])
+	ids := splitSet(q["ids"])
+	return func(e axcrud.Event) bool {
+		if e.Resource != resource {
+			return false
+		}
+		if e.TenantID != nil && (!hasTenant || fmt.Sprint(e.TenantID) != fmt.Sprint(tenantID)) {
+			return false
+		}
+		if len(types) > 0 && !types.Has(string(e.Type)) {
+			return false
+		}
+		if len(ids) > 0 && len(e.IDs) > 0 {
+			for _, id := range e.IDs {
+				if ids.Has(fmt.Sprint(id)) {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	}
+}
+
+func splitSet(values []string) axcrud.FieldSet {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return axcrud.NewFieldSet(out...)
+}
@@ -0,0 +1,454 @@
+package webcrud
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/axgrid/axcrud"
+)
+
+type ExportColumn struct {
+	Field  string // ключ в JSON-представлении DTO
+	Header string // заголовок колонки; по умолчанию Field
+}
+
+type ExportOptions struct {
+	// Разрешённые колонки и их порядок; по умолчанию — json-поля DTO в порядке объявления.
+	// Клиент может сузить набор через ?columns[]=...
+	Columns []ExportColumn
+	// Размер пачки при чтении из репозитория (по умолчанию 500)
+	BatchSize int
+	// Имя файла без расширения (по умолчанию "export")
+	Filename string
+}
+
+// exportWriter — построчная запись в конкретный формат
+type exportWriter interface {
+	Header(cols []ExportColumn) error
+	Row(cols []ExportColumn, row map[string]any) error
+	Close() error
+}
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// prepareExport — формат и колонки из query (?format=csv|ndjson|xlsx&columns[]=...)
+func prepareExport[DTO any](values url.Values, opts ExportOptions) (string, []ExportColumn, error) {
+	format := strings.ToLower(values.Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		return "", nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	allowed := opts.Columns
+	if len(allowed) == 0 {
+		for _, f := range jsonFields(reflect.TypeFor[DTO]()) {
+			allowed = append(allowed, ExportColumn{Field: f})
+		}
+	}
+	requested := values["columns[]"]
+	if len(requested) == 0 {
+		for _, v := range values["columns"] {
+			requested = append(requested, strings.Split(v, ",")...)
+		}
+	}
+	if len(requested) == 0 {
+		return format, allowed, nil
+	}
+	byField := make(map[string]ExportColumn, len(allowed))
+	for _, c := range allowed {
+		byField[c.Field] = c
+	}
+	cols := make([]ExportColumn, 0, len(requested))
+	for _, f := range requested {
+		f = strings.TrimSpace(f)
+		c, ok := byField[f]
+		if !ok && len(allowed) > 0 {
+			return "", nil, fmt.Errorf("export of column '%s' is not allowed", f)
+		}
+		if !ok {
+			c = ExportColumn{Field: f}
+		}
+		cols = append(cols, c)
+	}
+	return format, cols, nil
+}
+
+// streamExport читает все записи по refine-фильтрам через Iterate и пишет их в w пачками,
+// не держа выборку в памяти. Заголовки ответа должны быть уже выставлены.
+// Сортировка запроса не применяется: Iterate идёт keyset-курсором по первичному ключу.
+func streamExport[T any, ID IDConstraint, DTO any](ctx context.Context, w io.Writer, format string, cols []ExportColumn,
+	r axcrud.Repo[T, ID], lp axcrud.ListParams, tr TransformFn[T, DTO], batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	lp.Sort = nil
+	var ew exportWriter
+	switch format {
+	case "ndjson":
+		ew = &ndjsonWriter{w: bufio.NewWriter(w)}
+	case "xlsx":
+		xw, err := newXLSXWriter(w)
+		if err != nil {
+			return err
+		}
+		ew = xw
+	default:
+		ew = &csvWriter{w: csv.NewWriter(w)}
+	}
+	if cols != nil {
+		if err := ew.Header(cols); err != nil {
+			return err
+		}
+	}
+	flusher, _ := w.(http.Flusher)
+	_, err := r.Iterate(ctx, lp, batchSize, func(batch []T) error {
+		dtos, err := MapSlice(ctx, batch, tr)
+		if err != nil {
+			return err
+		}
+		for _, dto := range dtos {
+			row, err := toJSONMap(dto)
+			if err != nil {
+				return err
+			}
+			if cols == nil {
+				// DTO не структура — колонки по первой строке
+				cols = sortedKeys(row)
+				if err = ew.Header(cols); err != nil {
+					return err
+				}
+			}
+			if err = ew.Row(cols, row); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			if f, ok := ew.(interface{ Flush() error }); ok {
+				if err := f.Flush(); err != nil {
+					return err
+				}
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func exportHeaders(h http.Header, format string, opts ExportOptions) {
+	name := opts.Filename
+	if name == "" {
+		name = "export"
+	}
+	h.Set("Content-Type", exportContentTypes[format])
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	h.Set("Trailer", ExportErrorTrailer)
+}
+
+// ExportErrorTrailer — трейлер с ошибкой выгрузки, если соединение нельзя оборвать (HTTP/2)
+const ExportErrorTrailer = "X-Export-Error"
+
+// abortExport — статус 200 уже отправлен, кодом ответа ошибку не вернуть.
+// HTTP/1.x: соединение закрывается без завершающего chunk, и клиент видит обрыв, а не «целый» файл.
+// Где захватить соединение нельзя (HTTP/2), ошибка уходит в трейлер ExportErrorTrailer.
+func abortExport(w http.ResponseWriter, err error) {
+	// обёртки (gin, middleware) сами Hijack могут не поддерживать — берём исходный writer сервера
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	if conn, _, herr := http.NewResponseController(w).Hijack(); herr == nil {
+		_ = conn.Close()
+		return
+	}
+	w.Header().Set(ExportErrorTrailer, err.Error())
+}
+
+// ==== форматы ====
+
+type csvWriter struct{ w *csv.Writer }
+
+func (c *csvWriter) Header(cols []ExportColumn) error {
+	return c.w.Write(columnHeaders(cols))
+}
+
+func (c *csvWriter) Row(cols []ExportColumn, row map[string]any) error {
+	rec := make([]string, len(cols))
+	for i, col := range cols {
+		rec[i] = safeCell(row[col.Field])
+	}
+	return c.w.Write(rec)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error { return c.Flush() }
+
+type ndjsonWriter struct{ w *bufio.Writer }
+
+func (n *ndjsonWriter) Header([]ExportColumn) error { return nil }
+
+// Row — объект собирается вручную: map потерял бы порядок колонок, выбранный клиентом
+func (n *ndjsonWriter) Row(cols []ExportColumn, row map[string]any) error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, col := range cols {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(col.Field)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(row[col.Field])
+		if err != nil {
+			return err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteString("}\n")
+	_, err := n.w.Write(b.Bytes())
+	return err
+}
+
+func (n *ndjsonWriter) Flush() error { return n.w.Flush() }
+
+func (n *ndjsonWriter) Close() error { return n.w.Flush() }
+
+// xlsxWriter — минимальный SpreadsheetML: один лист, inline-строки, потоковая запись в zip
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+var xlsxStatic = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	x := &xlsxWriter{zw: zip.NewWriter(w)}
+	for _, f := range xlsxStatic {
+		fw, err := x.zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(fw, f.body); err != nil {
+			return nil, err
+		}
+	}
+	sheet, err := x.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x.sheet = sheet
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return x, err
+}
+
+func (x *xlsxWriter) Header(cols []ExportColumn) error {
+	headers := columnHeaders(cols)
+	vals := make([]any, len(headers))
+	for i := range headers {
+		vals[i] = headers[i]
+	}
+	return x.writeRow(vals)
+}
+
+func (x *xlsxWriter) Row(cols []ExportColumn, row map[string]any) error {
+	vals := make([]any, len(cols))
+	for i, col := range cols {
+		vals[i] = row[col.Field]
+	}
+	return x.writeRow(vals)
+}
+
+func (x *xlsxWriter) writeRow(vals []any) error {
+	x.row++
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<row r="%d">`, x.row)
+	for i, v := range vals {
+		ref := xlsxColumn(i) + strconv.Itoa(x.row)
+		switch t := v.(type) {
+		case nil:
+			continue
+		case json.Number:
+			fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, t)
+		case bool:
+			v := "0"
+			if t {
+				v = "1"
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="b"><v>%s</v></c>`, ref, v)
+		default:
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			_ = xml.EscapeText(&b, []byte(safeCell(v)))
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := x.sheet.Write(b.Bytes())
+	return err
+}
+
+func (x *xlsxWriter) Flush() error { return x.zw.Flush() }
+
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// ==== утилиты ====
+
+// xlsxColumn — 0 → A, 25 → Z, 26 → AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func columnHeaders(cols []ExportColumn) []string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = c.Header
+		if out[i] == "" {
+			out[i] = c.Field
+		}
+	}
+	return out
+}
+
+// safeCell — cellString с защитой от формул: строка, которую Excel/LibreOffice приняли бы
+// за формулу (=, +, -, @, tab, CR в начале), получает префикс '. Числа не трогаем.
+func safeCell(v any) string {
+	s := cellString(v)
+	if _, isString := v.(string); isString && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func cellString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+// toJSONMap — DTO в его JSON-представление (числа — json.Number, без потери точности)
+func toJSONMap(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out map[string]any
+	if err = dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func sortedKeys(m map[string]any) []ExportColumn {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cols := make([]ExportColumn, len(keys))
+	for i, k := range keys {
+		cols[i] = ExportColumn{Field: k}
+	}
+	return cols
+}
+
+// jsonFields — имена json-полей структуры в порядке объявления
+func jsonFields(t reflect.Type) []string {
+	var out []string
+	walkJSONFields(t, func(name string, _ reflect.Type) {
+		out = append(out, name)
+	})
+	return out
+}
+
+// walkJSONFields обходит поля структуры так, как их видит encoding/json
+// (json-теги, "-" пропускается, встроенные структуры раскрываются)
+func walkJSONFields(t reflect.Type, fn func(name string, ft reflect.Type)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			walkJSONFields(f.Type, fn)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fn(name, f.Type)
+	}
+}
@@ -14,15 +14,15 @@ func GinGetListT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Trans
 		req := ParseRefineQuery(c.Request.URL.Query())
 		lp := AdaptRefineList(req)
 
-		items, total, err := r.GetList(c, lp)
+		items, total, err := r.GetList(ginCtx(c), lp)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
-		dtos, err := MapSlice(c, items, tr)
+		dtos, err := MapSlice(ginCtx(c), items, tr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
@@ -35,20 +35,20 @@ func GinPostListT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Tran
 	return func(c *gin.Context) {
 		var in RefineListRequest
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		lp := AdaptRefineList(in)
 
-		items, total, err := r.GetList(c, lp)
+		items, total, err := r.GetList(ginCtx(c), lp)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
-		dtos, err := MapSlice(c, items, tr)
+		dtos, err := MapSlice(ginCtx(c), items, tr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
@@ -61,16 +61,16 @@ func GinCreateT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Transf
 	return func(c *gin.Context) {
 		var in T
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		if err := r.Create(c, &in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+		if err := r.Create(ginCtx(c), &in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		dto, err := tr(c, in)
+		dto, err := tr(ginCtx(c), in)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, OneResponseDTO[DTO]{Data: dto})
@@ -82,17 +82,17 @@ func GinGetOneT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Transf
 	return func(c *gin.Context) {
 		id, err := parseID[ID](c.Param("id"))
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		item, err := r.GetOne(c, id)
+		item, err := r.GetOne(ginCtx(c), id)
 		if err != nil {
-			c.AbortWithError(http.StatusNotFound, err)
+			abortWithError(c, http.StatusNotFound, err)
 			return
 		}
-		dto, err := tr(c, item)
+		dto, err := tr(ginCtx(c), item)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, OneResponseDTO[DTO]{Data: dto})
@@ -104,7 +104,7 @@ func GinGetManyT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Trans
 	return func(c *gin.Context) {
 		ids, ok, err := readIDsFromRequest[ID](c)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		if !ok {
@@ -112,19 +112,19 @@ func GinGetManyT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Trans
 			return
 		}
 
-		items, err := r.GetMany(c, ids)
+		res, err := r.GetManyOrdered(ginCtx(c), ids)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
-		dtos, err := MapSlice(c, items, tr)
+		dtos, err := MapSlice(ginCtx(c), res.Items, tr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, ManyResponseDTO[DTO]{Data: dtos})
+		c.JSON(http.StatusOK, ManyResponse[DTO, ID]{Data: dtos, Missing: res.Missing})
 	}
 }
 
@@ -133,24 +133,24 @@ func GinUpdateT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr Transf
 	return func(c *gin.Context) {
 		id, err := parseID[ID](c.Param("id"))
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
 		var patch map[string]any
 		if err := c.ShouldBindJSON(&patch); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		item, err := r.Update(c, id, patch)
+		item, err := r.Update(ginCtx(c), id, patch)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
-		dto, err := tr(c, item)
+		dto, err := tr(ginCtx(c), item)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 
@@ -163,11 +163,11 @@ func GinDeleteT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID]) gin.Handl
 	return func(c *gin.Context) {
 		id, err := parseID[ID](c.Param("id"))
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		if err := r.Delete(c, id); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+		if err := r.Delete(ginCtx(c), id); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, AffectedResponse{Data: 1})
@@ -179,18 +179,51 @@ func GinDeleteManyT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID]) gin.H
 	return func(c *gin.Context) {
 		var in idsReq[ID]
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		affected, err := r.DeleteMany(c, in.IDs)
+		affected, err := r.DeleteMany(ginCtx(c), in.IDs)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, AffectedResponse{Data: affected})
 	}
 }
 
+// GET /resource/export?format=csv|ndjson|xlsx&columns[]=...&filters[...]&q=...
+// Строки идут в порядке первичного ключа, sorters из запроса игнорируются.
+func GinExportT[T any, ID IDConstraint, DTO any](r axcrud.Repo[T, ID], tr TransformFn[T, DTO], opts ExportOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, cols, err := prepareExport[DTO](c.Request.URL.Query(), opts)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		lp := AdaptRefineList(ParseRefineQuery(c.Request.URL.Query()))
+		exportHeaders(c.Writer.Header(), format, opts)
+		c.Status(http.StatusOK)
+		c.Writer.WriteHeaderNow()
+		if err = streamExport(ginCtx(c), c.Writer, format, cols, r, lp, tr, opts.BatchSize); err != nil {
+			// статус уже отправлен — обрываем ответ, чтобы клиент не принял обрезанный файл за целый
+			abortExport(c.Writer, err)
+		}
+	}
+}
+
+// POST /resource/import?format=csv|ndjson&mode=insert|upsert&dryRun=true
+func GinImportT[T any, ID IDConstraint, In any](r axcrud.Repo[T, ID], from TransformFn[In, T], opts ImportOptions) gin.HandlerFunc {
+	checkImportOptions[T, In](opts)
+	return func(c *gin.Context) {
+		status, report, err := runImport(ginCtx(c), c.Request, r, from, opts)
+		if err != nil {
+			abortWithError(c, status, err)
+			return
+		}
+		c.JSON(status, report)
+	}
+}
+
 // --- утилита чтения ids для GET/POST
 func readIDsFromRequest[ID IDConstraint](c *gin.Context) ([]ID, bool, error) {
 	// POST JSON
@@ -2,6 +2,7 @@
 package webcrud
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/axgrid/axcrud"
@@ -22,9 +23,9 @@ func GinGetList[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		req := ParseRefineQuery(c.Request.URL.Query())
 		lp := AdaptRefineList(req)
-		items, total, err := r.GetList(c, lp)
+		items, total, err := r.GetList(ginCtx(c), lp)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, ListResponse[T]{Data: items, Total: total})
@@ -35,13 +36,13 @@ func GinPostList[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var in RefineListRequest
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		lp := AdaptRefineList(in)
-		items, total, err := r.GetList(c, lp)
+		items, total, err := r.GetList(ginCtx(c), lp)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, ListResponse[T]{Data: items, Total: total})
@@ -52,28 +53,96 @@ func GinCreate[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var in T
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		if err := r.Create(c, &in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+		if err := r.Create(ginCtx(c), &in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, OneResponse[T]{Data: in})
 	}
 }
 
+// POST /resource/aggregate  {filters, search, metrics: [{fn, field, as}], groupBy: [], bucket: {field, unit}}
+func GinAggregate[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in RefineAggregateRequest
+		if err := c.ShouldBindJSON(&in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		rows, err := r.Aggregate(ginCtx(c), AdaptRefineAggregate(in))
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, AggregateResponse{Data: rows})
+	}
+}
+
+// GET /resource/facets?fields[]=role&filters[...]&q=...
+func GinFacets[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := ParseFacetFields(c.Request.URL.Query())
+		if len(fields) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "fields required"})
+			return
+		}
+		lp := AdaptRefineList(ParseRefineQuery(c.Request.URL.Query()))
+		facets, err := r.Facets(ginCtx(c), lp, fields)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, FacetsResponse{Data: facets})
+	}
+}
+
+// POST /resource/createMany  {"items": [...], "batchSize": 100}
+func GinCreateMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in bulkReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		status, out := runBulk(ginCtx(c), in, r.CreateMany)
+		c.JSON(status, out)
+	}
+}
+
+// POST /resource/upsert  {"items": [...], "batchSize": 100, "conflictColumns": [...], "updateColumns": [...]}
+func GinUpsert[T any, ID IDConstraint](r axcrud.Repo[T, ID], opts UpsertOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in bulkReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		p, err := upsertParams(in, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, BulkResponse[ID]{Error: err.Error()})
+			return
+		}
+		status, out := runBulk(ginCtx(c), in, func(ctx context.Context, items []T, _ int) ([]ID, error) {
+			return r.UpsertMany(ctx, items, p)
+		})
+		c.JSON(status, out)
+	}
+}
+
 func GinGetOne[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		item, err := r.GetOne(c, id)
+		item, err := r.GetOne(ginCtx(c), id)
 		if err != nil {
-			c.AbortWithError(http.StatusNotFound, err)
+			abortWithError(c, http.StatusNotFound, err)
 			return
 		}
 		c.JSON(http.StatusOK, OneResponse[T]{Data: item})
@@ -98,24 +167,22 @@ func GinGetMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 				for _, s := range idsQ {
 					id, err := parseID[ID](s)
 					if err != nil {
-						c.AbortWithError(http.StatusBadRequest, err)
+						abortWithError(c, http.StatusBadRequest, err)
 						return
 					}
 					in.IDs = append(in.IDs, id)
 				}
 			} else {
-				c.AbortWithError(http.StatusBadRequest, err)
+				abortWithError(c, http.StatusBadRequest, err)
 				return
 			}
 		}
-		items, err := r.GetMany(c, in.IDs)
+		res, err := r.GetManyOrdered(ginCtx(c), in.IDs)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		c.JSON(http.StatusOK, struct {
-			Data []T `json:"data"`
-		}{Data: items})
+		c.JSON(http.StatusOK, ManyResponse[T, ID]{Data: res.Items, Missing: res.Missing})
 	}
 }
 
@@ -124,38 +191,55 @@ func GinUpdate[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		var patch map[string]any
 		if err := c.ShouldBindJSON(&patch); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		item, err := r.Update(c, id, patch)
+		item, err := r.Update(ginCtx(c), id, patch)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, OneResponse[T]{Data: item})
 	}
 }
 
+// PATCH /resource  и  POST /resource/updateMany
+func GinUpdateMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in updateManyReq[ID]
+		if err := c.ShouldBindJSON(&in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		affected, err := runUpdateMany(ginCtx(c), r, in)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, AffectedResponse{Data: affected})
+	}
+}
+
 func GinSave[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		var in T
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		if _, err := r.Save(c, id, in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+		if _, err := r.Save(ginCtx(c), id, in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, OneResponse[T]{Data: in})
@@ -167,11 +251,11 @@ func GinDelete[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := parseID[ID](idStr)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		if err := r.Delete(c, id); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+		if err := r.Delete(ginCtx(c), id); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, AffectedResponse{Data: 1})
@@ -182,14 +266,49 @@ func GinDeleteMany[T any, ID IDConstraint](r axcrud.Repo[T, ID]) gin.HandlerFunc
 	return func(c *gin.Context) {
 		var in idsReq[ID]
 		if err := c.ShouldBindJSON(&in); err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
-		affected, err := r.DeleteMany(c, in.IDs)
+		affected, err := r.DeleteMany(ginCtx(c), in.IDs)
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
+			abortWithError(c, http.StatusBadRequest, err)
 			return
 		}
 		c.JSON(http.StatusOK, AffectedResponse{Data: affected})
 	}
 }
+
+// POST /_batch  {"operations": [{op, resource, id, data, ref}, ...]}
+func GinBatch(tm *axcrud.TxManager, reg *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in BatchRequest
+		if err := c.ShouldBindJSON(&in); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		status, out := runBatch(ginCtx(c), tm, reg, in)
+		c.JSON(status, out)
+	}
+}
+
+// ginContext — контекст для репозитория из Gin: значения c.Request.Context() (транзакция, тенант и т.п.),
+// затем ключи gin (c.Set). Сам gin.Context без ContextWithFallback в контекст запроса не заглядывает.
+type ginContext struct {
+	context.Context
+	c *gin.Context
+}
+
+func (g ginContext) Value(key any) any {
+	if v := g.Context.Value(key); v != nil {
+		return v
+	}
+	return g.c.Value(key)
+}
+
+func ginCtx(c *gin.Context) context.Context {
+	return ginContext{Context: c.Request.Context(), c: c}
+}
+
+func abortWithError(c *gin.Context, fallback int, err error) {
+	c.AbortWithError(errorStatus(err, fallback), err)
+}
@@ -3,16 +3,49 @@ package webcrud
 import (
 	"github.com/axgrid/axcrud"
 	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
 )
 
 func CreateGinRouter[T any, ID IDConstraint](r *gin.RouterGroup, repo axcrud.Repo[T, ID]) {
 	r.GET("/", GinGetList[T, ID](repo))
 	r.POST("/list", GinPostList[T, ID](repo))
+	r.POST("/aggregate", GinAggregate[T, ID](repo))
+	r.GET("/facets", GinFacets[T, ID](repo))
 	r.POST("/", GinCreate[T, ID](repo))
+	r.POST("/createMany", GinCreateMany[T, ID](repo))
+	r.POST("/upsert", GinUpsert[T, ID](repo, UpsertOptions{}))
 	r.GET("/:id", GinGetOne[T, ID](repo))
 	r.GET("/many", GinGetMany[T, ID](repo))     // GET ids[]=...
 	r.POST("/getMany", GinGetMany[T, ID](repo)) // POST {ids:[]}
+	r.PATCH("/", GinUpdateMany[T, ID](repo))
+	r.POST("/updateMany", GinUpdateMany[T, ID](repo))
 	r.PATCH("/:id", GinUpdate[T, ID](repo))
 	r.DELETE("/:id", GinDelete[T, ID](repo))
 	r.POST("/deleteMany", GinDeleteMany[T, ID](repo))
+	if vr, ok := repo.(axcrud.VersionedRepo[T, ID]); ok {
+		r.GET("/:id/versions", GinVersions[T, ID](vr))
+		r.POST("/:id/revert", GinRevert[T, ID](vr))
+	}
+}
+
+func CreateChiRouter[T any, ID IDConstraint](r chi.Router, repo axcrud.Repo[T, ID]) {
+	r.Get("/", ChiGetList[T, ID](repo))
+	r.Post("/list", ChiPostList[T, ID](repo))
+	r.Post("/aggregate", ChiAggregate[T, ID](repo))
+	r.Get("/facets", ChiFacets[T, ID](repo))
+	r.Post("/", ChiCreate[T, ID](repo))
+	r.Post("/createMany", ChiCreateMany[T, ID](repo))
+	r.Post("/upsert", ChiUpsert[T, ID](repo, UpsertOptions{}))
+	r.Get("/many", ChiGetMany[T, ID](repo))     // GET ids[]=...
+	r.Post("/getMany", ChiGetMany[T, ID](repo)) // POST {ids:[]}
+	r.Get("/{id}", ChiGetOne[T, ID](repo))
+	r.Patch("/", ChiUpdateMany[T, ID](repo))
+	r.Post("/updateMany", ChiUpdateMany[T, ID](repo))
+	r.Patch("/{id}", ChiUpdate[T, ID](repo))
+	r.Delete("/{id}", ChiDelete[T, ID](repo))
+	r.Post("/deleteMany", ChiDeleteMany[T, ID](repo))
+	if vr, ok := repo.(axcrud.VersionedRepo[T, ID]); ok {
+		r.Get("/{id}/versions", ChiVersions[T, ID](vr))
+		r.Post("/{id}/revert", ChiRevert[T, ID](vr))
+	}
 }
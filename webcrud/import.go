@@ -0,0 +1,300 @@
+package webcrud
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/axgrid/axcrud"
+)
+
+type ImportOptions struct {
+	// Размер пачки INSERT (по умолчанию 500)
+	BatchSize int
+	// Максимум строк в файле (по умолчанию 10000)
+	MaxRows int
+	// Максимальный размер загрузки в байтах (по умолчанию 32 МБ)
+	MaxBytes int64
+	// Колонки (json-поля In), которые можно загружать; пусто — все поля In.
+	// Если In — сама модель, список обязателен: иначе из файла пришли бы PK и служебные колонки.
+	Columns []string
+}
+
+// checkImportOptions — ошибка конфигурации, а не запроса: ChiImportT/GinImportT паникуют при создании
+func checkImportOptions[T, In any](opts ImportOptions) {
+	if len(opts.Columns) == 0 && reflect.TypeFor[In]() == reflect.TypeFor[T]() {
+		panic(fmt.Sprintf("webcrud: import into %s without a DTO requires ImportOptions.Columns", reflect.TypeFor[T]()))
+	}
+}
+
+// importColumns — разрешённые колонки: ImportOptions.Columns или все json-поля In
+func importColumns[In any](opts ImportOptions) map[string]reflect.Type {
+	types := jsonFieldTypes(reflect.TypeFor[In]())
+	if len(opts.Columns) == 0 {
+		return types
+	}
+	allowed := make(map[string]reflect.Type, len(opts.Columns))
+	for _, c := range opts.Columns {
+		if t, ok := types[c]; ok {
+			allowed[c] = t
+		}
+	}
+	return allowed
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"` // CSV: номер строки файла (заголовок — 1); NDJSON: номер строки с 1
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int64            `json:"imported"`
+	DryRun   bool             `json:"dryRun"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// Validator — если входной DTO его реализует, Validate вызывается для каждой строки импорта
+type Validator interface {
+	Validate() error
+}
+
+// runImport — общая часть import для Chi и Gin.
+//
+//	POST /resource/import?format=csv|ndjson&mode=insert|upsert&dryRun=true
+//
+// Файл — multipart-поле "file" или сырое тело запроса. Каждая строка декодируется во входной DTO In
+// (колонки CSV — json-поля In), проверяется через Validator и переводится в T через from.
+// Если хоть одна строка невалидна, ничего не пишется (422 + отчёт). Иначе все строки
+// вставляются (или upsert по RepoConfig.UpsertConflictColumns) пачками в одной транзакции.
+func runImport[T any, ID IDConstraint, In any](ctx context.Context, req *http.Request, r axcrud.Repo[T, ID],
+	from TransformFn[In, T], opts ImportOptions) (int, ImportReport, error) {
+	q := req.URL.Query()
+	report := ImportReport{DryRun: q.Get("dryRun") == "true" || q.Get("dryRun") == "1"}
+	mode := strings.ToLower(q.Get("mode"))
+	if mode != "" && mode != "insert" && mode != "upsert" {
+		return http.StatusBadRequest, report, fmt.Errorf("unsupported import mode: %s", mode)
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 32 << 20
+	}
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	body, format, err := importSource(req, opts.MaxBytes)
+	if err != nil {
+		return errorStatus(err, http.StatusBadRequest), report, err
+	}
+	if f := strings.ToLower(q.Get("format")); f != "" {
+		format = f
+	}
+
+	columns := importColumns[In](opts)
+	var rows []importRow[In]
+	switch format {
+	case "csv":
+		rows, err = decodeCSV[In](body, opts.MaxRows, columns)
+	case "ndjson", "jsonl":
+		rows, err = decodeNDJSON[In](body, opts.MaxRows, columns)
+	default:
+		err = fmt.Errorf("unsupported import format: %q", format)
+	}
+	if err != nil {
+		return errorStatus(err, http.StatusBadRequest), report, err
+	}
+
+	report.Total = len(rows)
+	items := make([]T, 0, len(rows))
+	for _, row := range rows {
+		if row.err == nil {
+			if v, ok := any(&row.in).(Validator); ok {
+				row.err = v.Validate()
+			}
+		}
+		var item T
+		if row.err == nil {
+			item, row.err = from(ctx, row.in)
+		}
+		if row.err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Row: row.line, Error: row.err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+	report.Valid = len(items)
+	if len(report.Errors) > 0 {
+		return http.StatusUnprocessableEntity, report, nil
+	}
+	if report.DryRun || len(items) == 0 {
+		return http.StatusOK, report, nil
+	}
+
+	var ids []ID
+	if mode == "upsert" {
+		ids, err = r.UpsertMany(ctx, items, axcrud.UpsertParams{BatchSize: opts.BatchSize})
+	} else {
+		ids, err = r.CreateMany(ctx, items, opts.BatchSize)
+	}
+	if err != nil {
+		return errorStatus(err, http.StatusBadRequest), report, err
+	}
+	report.Imported = int64(len(ids))
+	return http.StatusOK, report, nil
+}
+
+type importRow[In any] struct {
+	line int
+	in   In
+	err  error
+}
+
+// importSource — файл из multipart-поля "file" либо тело запроса; формат — по расширению/Content-Type
+func importSource(req *http.Request, maxBytes int64) (io.Reader, string, error) {
+	ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if ct == "multipart/form-data" {
+		if err := req.ParseMultipartForm(maxBytes); err != nil {
+			return nil, "", err
+		}
+		f, hdr, err := req.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		return io.LimitReader(f, maxBytes), strings.TrimPrefix(strings.ToLower(path.Ext(hdr.Filename)), "."), nil
+	}
+	format := ""
+	switch ct {
+	case "text/csv":
+		format = "csv"
+	case "application/x-ndjson", "application/jsonl":
+		format = "ndjson"
+	}
+	return io.LimitReader(req.Body, maxBytes), format, nil
+}
+
+func decodeCSV[In any](src io.Reader, maxRows int, types map[string]reflect.Type) ([]importRow[In], error) {
+	cr := csv.NewReader(src)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	for i, h := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if _, ok := types[header[i]]; !ok {
+			return nil, fmt.Errorf("unknown column '%s'", header[i])
+		}
+	}
+
+	var rows []importRow[In]
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("too many rows (max %d)", maxRows)
+		}
+		row := importRow[In]{line: line}
+		if err != nil {
+			row.err = err
+			rows = append(rows, row)
+			continue
+		}
+		obj := make(map[string]any, len(header))
+		for i, cell := range rec {
+			if i >= len(header) {
+				row.err = fmt.Errorf("too many values")
+				break
+			}
+			if cell == "" {
+				continue
+			}
+			if obj[header[i]], err = csvValue(cell, types[header[i]]); err != nil {
+				row.err = fmt.Errorf("column '%s': %w", header[i], err)
+				break
+			}
+		}
+		if row.err == nil {
+			var b []byte
+			if b, row.err = json.Marshal(obj); row.err == nil {
+				row.err = json.Unmarshal(b, &row.in)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func decodeNDJSON[In any](src io.Reader, maxRows int, types map[string]reflect.Type) ([]importRow[In], error) {
+	sc := bufio.NewScanner(src)
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	var rows []importRow[In]
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("too many rows (max %d)", maxRows)
+		}
+		row := importRow[In]{line: line}
+		var keys map[string]json.RawMessage
+		if row.err = json.Unmarshal(b, &keys); row.err == nil {
+			for k := range keys {
+				if _, ok := types[k]; !ok {
+					row.err = fmt.Errorf("unknown column '%s'", k)
+					break
+				}
+			}
+		}
+		if row.err == nil {
+			row.err = json.Unmarshal(b, &row.in)
+		}
+		rows = append(rows, row)
+	}
+	return rows, sc.Err()
+}
+
+// csvValue — строка CSV в значение для json по типу поля DTO (числа/bool без кавычек)
+func csvValue(cell string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %q", cell)
+		}
+		return json.Number(cell), nil
+	case reflect.Bool:
+		return strconv.ParseBool(cell)
+	default:
+		return cell, nil
+	}
+}
+
+// jsonFieldTypes — json-имя поля → тип
+func jsonFieldTypes(t reflect.Type) map[string]reflect.Type {
+	out := map[string]reflect.Type{}
+	walkJSONFields(t, func(name string, ft reflect.Type) {
+		out[name] = ft
+	})
+	return out
+}
@@ -0,0 +1,144 @@
+package webcrud
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// httpObserver — span, метрики axcrud.http.* и лог на каждый запрос
+type httpObserver struct {
+	opts axcrud.ObserveOptions
+	in   axcrud.Instruments
+}
+
+func newHTTPObserver(opts axcrud.ObserveOptions) (*httpObserver, error) {
+	if opts.Tracer == nil {
+		opts.Tracer = otel.Tracer(axcrud.InstrumentationName)
+	}
+	if opts.Meter == nil {
+		opts.Meter = otel.Meter(axcrud.InstrumentationName)
+	}
+	in, err := axcrud.NewInstruments(opts.Meter, "axcrud.http")
+	if err != nil {
+		return nil, err
+	}
+	return &httpObserver{opts: opts, in: in}, nil
+}
+
+// start — span с контекстом трассировки из заголовков (traceparent и т.п.)
+func (o *httpObserver) start(req *http.Request) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	return o.opts.Tracer.Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("http.request.method", req.Method)))
+}
+
+// finish — route — шаблон маршрута ("/users/{id}"), а не путь: так метрики не размножаются по ID
+func (o *httpObserver) finish(ctx context.Context, span trace.Span, method, route string, status int, elapsed time.Duration) {
+	defer span.End()
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.Int("http.response.status_code", status),
+	}
+	span.SetName(method + " " + route)
+	span.SetAttributes(attrs...)
+	class := statusClass(status)
+	if class != "" {
+		attrs = append(attrs, attribute.String("error.class", class))
+		o.in.Errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+	o.in.Duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+
+	if o.opts.Logger == nil {
+		return
+	}
+	level := slog.LevelDebug
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400, o.opts.SlowThreshold > 0 && elapsed >= o.opts.SlowThreshold:
+		level = slog.LevelWarn
+	}
+	o.opts.Logger.LogAttrs(ctx, level, "axcrud http",
+		slog.String("method", method), slog.String("route", route),
+		slog.Int("status", status), slog.Duration("duration", elapsed))
+}
+
+// statusClass — класс ошибки (как axcrud.ErrorClass) по HTTP-статусу
+func statusClass(status int) string {
+	switch {
+	case status < 400:
+		return ""
+	case status == http.StatusNotFound:
+		return axcrud.ErrClassNotFound
+	case status == http.StatusForbidden, status == http.StatusUnauthorized:
+		return axcrud.ErrClassForbidden
+	case status == http.StatusConflict:
+		return axcrud.ErrClassConflict
+	case status == http.StatusGatewayTimeout, status == http.StatusRequestTimeout:
+		return axcrud.ErrClassTimeout
+	case status < 500:
+		return axcrud.ErrClassInvalid
+	}
+	return axcrud.ErrClassInternal
+}
+
+// ChiObserveMiddleware — трейсы, метрики (axcrud.http.duration/errors) и slog-лог запросов
+func ChiObserveMiddleware(opts axcrud.ObserveOptions) (func(http.Handler) http.Handler, error) {
+	o, err := newHTTPObserver(opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx, span := o.start(req)
+			// обёртка chi сохраняет Flusher/Hijacker — нужны SSE и WebSocket
+			ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+			start := time.Now()
+			next.ServeHTTP(ww, req.WithContext(ctx))
+			route := "unmatched"
+			if rc := chi.RouteContext(req.Context()); rc != nil && rc.RoutePattern() != "" {
+				route = rc.RoutePattern()
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			o.finish(ctx, span, req.Method, route, status, time.Since(start))
+		})
+	}, nil
+}
+
+func GinObserveMiddleware(opts axcrud.ObserveOptions) (gin.HandlerFunc, error) {
+	o, err := newHTTPObserver(opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *gin.Context) {
+		ctx, span := o.start(c.Request)
+		c.Request = c.Request.WithContext(ctx)
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		o.finish(ctx, span, c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}, nil
+}
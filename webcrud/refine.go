@@ -77,6 +77,42 @@ func AdaptRefineList(req RefineListRequest) axcrud.ListParams {
 	return lp
 }
 
+// ===== Aggregate =====
+
+type RefineMetric struct {
+	Fn    string `json:"fn"` // count, sum, avg, min, max
+	Field string `json:"field"`
+	As    string `json:"as"`
+}
+
+type RefineDateBucket struct {
+	Field string `json:"field"`
+	Unit  string `json:"unit"` // hour, day, month, year
+	As    string `json:"as"`
+}
+
+// RefineAggregateRequest — filters/search как у списка + метрики и группировка
+type RefineAggregateRequest struct {
+	RefineListRequest
+	Metrics []RefineMetric    `json:"metrics"`
+	GroupBy []string          `json:"groupBy"`
+	Bucket  *RefineDateBucket `json:"bucket"`
+}
+
+func AdaptRefineAggregate(req RefineAggregateRequest) axcrud.AggregateParams {
+	ap := axcrud.AggregateParams{
+		ListParams: AdaptRefineList(req.RefineListRequest),
+		GroupBy:    req.GroupBy,
+	}
+	for _, m := range req.Metrics {
+		ap.Metrics = append(ap.Metrics, axcrud.Metric{Func: m.Fn, Field: m.Field, As: m.As})
+	}
+	if req.Bucket != nil {
+		ap.Bucket = &axcrud.DateBucket{Field: req.Bucket.Field, Unit: req.Bucket.Unit, As: req.Bucket.As}
+	}
+	return ap
+}
+
 func normalizeOrder(s string) string {
 	if strings.EqualFold(s, "desc") {
 		return "desc"
@@ -75,6 +75,23 @@ func ParseRefineQuery(values url.Values) RefineListRequest {
 	return req
 }
 
+// ParseFacetFields — ?fields[]=role&fields[]=status (или fields=role,status)
+func ParseFacetFields(values url.Values) []string {
+	fields := values["fields[]"]
+	if len(fields) == 0 {
+		for _, v := range values["fields"] {
+			fields = append(fields, strings.Split(v, ",")...)
+		}
+	}
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
 // ==== helpers (локальные) ====
 
 func atoi(s string) int {
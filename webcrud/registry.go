@@ -0,0 +1,117 @@
+package webcrud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/axgrid/axcrud"
+)
+
+// Resource — CRUD над одним репозиторием без дженериков: ID — строкой, тела — JSON.
+// Нужен обработчикам, работающим с несколькими ресурсами сразу (batch, WebSocket).
+type Resource interface {
+	List(ctx context.Context, in RefineListRequest) (items any, total int64, err error)
+	One(ctx context.Context, id string) (any, error)
+	Create(ctx context.Context, data json.RawMessage) (id any, out any, err error)
+	Update(ctx context.Context, id string, patch map[string]any) (any, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type resource[T any, ID IDConstraint, DTO any] struct {
+	repo axcrud.Repo[T, ID]
+	tr   TransformFn[T, DTO]
+}
+
+// NewResource — Resource поверх Repo; tr — как у *-T хендлеров (Identity[T], если DTO не нужен).
+func NewResource[T any, ID IDConstraint, DTO any](repo axcrud.Repo[T, ID], tr TransformFn[T, DTO]) Resource {
+	return &resource[T, ID, DTO]{repo: repo, tr: tr}
+}
+
+func (r *resource[T, ID, DTO]) List(ctx context.Context, in RefineListRequest) (any, int64, error) {
+	items, total, err := r.repo.GetList(ctx, AdaptRefineList(in))
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos, err := MapSlice(ctx, items, r.tr)
+	if err != nil {
+		return nil, 0, err
+	}
+	return dtos, total, nil
+}
+
+func (r *resource[T, ID, DTO]) One(ctx context.Context, idStr string) (any, error) {
+	id, err := parseID[ID](idStr)
+	if err != nil {
+		return nil, err
+	}
+	item, err := r.repo.GetOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.tr(ctx, item)
+}
+
+func (r *resource[T, ID, DTO]) Create(ctx context.Context, data json.RawMessage) (any, any, error) {
+	items := make([]T, 1)
+	if err := json.Unmarshal(data, &items[0]); err != nil {
+		return nil, nil, err
+	}
+	ids, err := r.repo.CreateMany(ctx, items, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	dto, err := r.tr(ctx, items[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return ids[0], dto, nil
+}
+
+func (r *resource[T, ID, DTO]) Update(ctx context.Context, idStr string, patch map[string]any) (any, error) {
+	id, err := parseID[ID](idStr)
+	if err != nil {
+		return nil, err
+	}
+	item, err := r.repo.Update(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	return r.tr(ctx, item)
+}
+
+func (r *resource[T, ID, DTO]) Delete(ctx context.Context, idStr string) error {
+	id, err := parseID[ID](idStr)
+	if err != nil {
+		return err
+	}
+	return r.repo.Delete(ctx, id)
+}
+
+// Registry — ресурсы по именам ("users", "orders", ...)
+type Registry struct {
+	mu        sync.RWMutex
+	resources map[string]Resource
+}
+
+func NewRegistry() *Registry {
+	return &Registry{resources: map[string]Resource{}}
+}
+
+func (g *Registry) Register(name string, res Resource) *Registry {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.resources[name] = res
+	return g
+}
+
+func (g *Registry) Get(name string) (Resource, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	res, ok := g.resources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource '%s'", name)
+	}
+	return res, nil
+}
@@ -1,5 +1,7 @@
 package webcrud
 
+import "github.com/axgrid/axcrud"
+
 type ListResponse[T any] struct {
 	Data  []T   `json:"data"`
 	Total int64 `json:"total"`
@@ -9,6 +11,32 @@ type OneResponse[T any] struct {
 	Data T `json:"data"`
 }
 
+type ManyResponse[T any, ID any] struct {
+	Data    []T  `json:"data"`
+	Missing []ID `json:"missing,omitempty"`
+}
+
 type AffectedResponse struct {
 	Data int64 `json:"data"`
 }
+
+type BulkItemResult[ID any] struct {
+	Index int    `json:"index"`
+	ID    *ID    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type BulkResponse[ID any] struct {
+	Data     []BulkItemResult[ID] `json:"data"`
+	Affected int64                `json:"affected"`
+	Error    string               `json:"error,omitempty"`
+	Batch    *int                 `json:"batch,omitempty"` // номер упавшей пачки; в data — её первый элемент
+}
+
+type AggregateResponse struct {
+	Data []axcrud.AggregateRow `json:"data"`
+}
+
+type FacetsResponse struct {
+	Data map[string][]axcrud.FacetValue `json:"data"`
+}
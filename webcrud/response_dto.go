@@ -10,6 +10,8 @@ type OneResponseDTO[DTO any] struct {
 	Data DTO `json:"data"`
 }
 
+// Deprecated: getMany отвечает ManyResponse (с полем missing); тип не используется хендлерами
+// и оставлен только для совместимости.
 type ManyResponseDTO[DTO any] struct {
 	Data []DTO `json:"data"`
 }
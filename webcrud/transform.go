@@ -11,6 +11,11 @@ import (
 // TransformFn — функция преобразования доменной модели в DTO для выдачи наружу.
 type TransformFn[T any, DTO any] func(ctx context.Context, src T) (DTO, error)
 
+// Identity — TransformFn, отдающая модель как есть (для хендлеров, которым нужен TransformFn).
+func Identity[T any](_ context.Context, src T) (T, error) {
+	return src, nil
+}
+
 // MapSlice — утилита для маппинга слайса через TransformFn.
 func MapSlice[T any, DTO any](ctx context.Context, in []T, fn TransformFn[T, DTO]) ([]DTO, error) {
 	if fn == nil {
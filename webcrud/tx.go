@@ -0,0 +1,119 @@
+package webcrud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+)
+
+var errRollback = errors.New("rollback: non-2xx response")
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func is2xx(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// ChiTxMiddleware оборачивает изменяющие запросы (POST/PUT/PATCH/DELETE) в транзакцию.
+// Ответ буферизуется: commit только при 2xx, и клиент получает ответ уже после commit.
+// Паника хендлера откатывает транзакцию и уходит выше без буфера — её ответ пишет внешний Recoverer.
+func ChiTxMiddleware(tm *axcrud.TxManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !isMutating(req.Method) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			bw := &bufferedWriter{header: http.Header{}, status: http.StatusOK}
+			err := tm.RunInTx(req.Context(), func(ctx context.Context) error {
+				next.ServeHTTP(bw, req.WithContext(ctx))
+				if !is2xx(bw.status) {
+					return errRollback
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, errRollback) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for k, v := range bw.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(bw.status)
+			_, _ = w.Write(bw.buf.Bytes())
+		})
+	}
+}
+
+type bufferedWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header         { return b.header }
+func (b *bufferedWriter) Write(p []byte) (int, error) { return b.buf.Write(p) }
+func (b *bufferedWriter) WriteHeader(status int)      { b.status = status }
+
+// GinTxMiddleware — то же, что ChiTxMiddleware, для Gin.
+// Транзакция кладётся в c.Request.Context(), хендлеры webcrud передают его в репозиторий.
+func GinTxMiddleware(tm *axcrud.TxManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		orig := c.Writer
+		bw := &ginBufferedWriter{ResponseWriter: orig, status: http.StatusOK}
+		err := func() error {
+			c.Writer = bw
+			// и при панике: иначе внешний Recovery напишет 500 в буфер, который никто не отправит
+			defer func() { c.Writer = orig }()
+			return tm.RunInTx(c.Request.Context(), func(ctx context.Context) error {
+				c.Request = c.Request.WithContext(ctx)
+				c.Next()
+				if !is2xx(bw.status) {
+					return errRollback
+				}
+				return nil
+			})
+		}()
+		if err != nil && !errors.Is(err, errRollback) {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		orig.WriteHeader(bw.status)
+		orig.WriteHeaderNow()
+		_, _ = orig.Write(bw.buf.Bytes())
+	}
+}
+
+// ginBufferedWriter копит тело и статус до решения о commit/rollback
+type ginBufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *ginBufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+func (w *ginBufferedWriter) WriteHeaderNow()                   {}
+func (w *ginBufferedWriter) Write(b []byte) (int, error)       { return w.buf.Write(b) }
+func (w *ginBufferedWriter) WriteString(s string) (int, error) { return w.buf.WriteString(s) }
+func (w *ginBufferedWriter) Status() int                       { return w.status }
+func (w *ginBufferedWriter) Size() int                         { return w.buf.Len() }
+func (w *ginBufferedWriter) Written() bool                     { return w.buf.Len() > 0 }
+func (w *ginBufferedWriter) Flush()                            {}
@@ -0,0 +1,175 @@
+package webcrud
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+)
+
+type RevertRequest struct {
+	Version int `json:"version"`
+}
+
+type DiffResponse struct {
+	Data []axcrud.FieldChange `json:"data"`
+}
+
+// versionQuery — ?at=RFC3339 (asOf) или ?from=&to= (diff); пустой параметр пропускается
+func versionQuery(q map[string][]string) (at time.Time, from, to int, err error) {
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	if s := get("at"); s != "" {
+		if at, err = time.Parse(time.RFC3339, s); err != nil {
+			return
+		}
+	}
+	if s := get("from"); s != "" {
+		if from, err = strconv.Atoi(s); err != nil {
+			return
+		}
+	}
+	if s := get("to"); s != "" {
+		to, err = strconv.Atoi(s)
+	}
+	return
+}
+
+// GET /{id}/versions — история записи; ?at=RFC3339 — состояние на момент, ?from=1&to=3 — разница версий
+func ChiVersions[T any, ID IDConstraint](r axcrud.VersionedRepo[T, ID]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, err := parseID[ID](chi.URLParam(req, "id"))
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		q := req.URL.Query()
+		at, from, to, err := versionQuery(q)
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		switch {
+		case !at.IsZero():
+			item, err := r.AsOf(req.Context(), id, at)
+			if err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			WriteJSON(w, http.StatusOK, OneResponse[T]{Data: item})
+		case q.Has("from") || q.Has("to"):
+			changes, err := r.Diff(req.Context(), id, from, to)
+			if err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			WriteJSON(w, http.StatusOK, DiffResponse{Data: changes})
+		default:
+			vs, err := r.Versions(req.Context(), id)
+			if err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			WriteJSON(w, http.StatusOK, ListResponse[axcrud.Version[T]]{Data: vs, Total: int64(len(vs))})
+		}
+	}
+}
+
+// POST /{id}/revert { "version": 3 } — вернуть запись к версии (создаёт новую версию)
+func ChiRevert[T any, ID IDConstraint](r axcrud.VersionedRepo[T, ID]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, err := parseID[ID](chi.URLParam(req, "id"))
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		var body RevertRequest
+		if err = json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		if body.Version <= 0 {
+			writeError(w, errors.New("version required"), http.StatusBadRequest)
+			return
+		}
+		item, err := r.Revert(req.Context(), id, body.Version)
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, http.StatusOK, OneResponse[T]{Data: item})
+	}
+}
+
+func GinVersions[T any, ID IDConstraint](r axcrud.VersionedRepo[T, ID]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := parseID[ID](c.Param("id"))
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		q := c.Request.URL.Query()
+		at, from, to, err := versionQuery(q)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		switch {
+		case !at.IsZero():
+			item, err := r.AsOf(ginCtx(c), id, at)
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, err)
+				return
+			}
+			c.JSON(http.StatusOK, OneResponse[T]{Data: item})
+		case q.Has("from") || q.Has("to"):
+			changes, err := r.Diff(ginCtx(c), id, from, to)
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, err)
+				return
+			}
+			c.JSON(http.StatusOK, DiffResponse{Data: changes})
+		default:
+			vs, err := r.Versions(ginCtx(c), id)
+			if err != nil {
+				abortWithError(c, http.StatusBadRequest, err)
+				return
+			}
+			c.JSON(http.StatusOK, ListResponse[axcrud.Version[T]]{Data: vs, Total: int64(len(vs))})
+		}
+	}
+}
+
+func GinRevert[T any, ID IDConstraint](r axcrud.VersionedRepo[T, ID]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := parseID[ID](c.Param("id"))
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		var body RevertRequest
+		if err = c.ShouldBindJSON(&body); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		if body.Version <= 0 {
+			abortWithError(c, http.StatusBadRequest, errors.New("version required"))
+			return
+		}
+		item, err := r.Revert(ginCtx(c), id, body.Version)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, OneResponse[T]{Data: item})
+	}
+}
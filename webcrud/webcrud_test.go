@@ -0,0 +1,617 @@
+package webcrud
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/assert/v2"
+	"golang.org/x/net/websocket"
+	"gorm.io/driver/sqlite" // Sqlite driver based on CGO
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+type TestItem struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+// newTestDB — отдельная in-memory БД на тест (shared cache, чтобы её видели все соединения пула)
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&TestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	return db
+}
+
+func newItemRepo(db *gorm.DB) *axcrud.GormRepo[TestItem, uint] {
+	return axcrud.NewGormRepo[TestItem, uint](db, axcrud.RepoConfig{
+		AllowedFilterOps:  map[string]axcrud.FieldSet{"name": axcrud.NewFieldSet("eq"), "price": axcrud.NewFieldSet("gte")},
+		AllowedSortFields: axcrud.NewFieldSet("id", "name", "price"),
+	})
+}
+
+func seedItems(t *testing.T, db *gorm.DB, names ...string) {
+	t.Helper()
+	for i, n := range names {
+		if err := db.Create(&TestItem{Name: n, Price: (i + 1) * 10}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func doRequest(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestExport(t *testing.T) {
+	db := newTestDB(t)
+	seedItems(t, db, "b", "c", "a")
+	repo := newItemRepo(db)
+
+	r := chi.NewRouter()
+	r.Get("/items/export", ChiExportT[TestItem, uint](repo, Identity[TestItem], ExportOptions{BatchSize: 2}))
+	g := gin.New()
+	g.GET("/items/export", GinExportT[TestItem, uint](repo, Identity[TestItem], ExportOptions{BatchSize: 2}))
+
+	for _, h := range []http.Handler{r, g} {
+		// sorters игнорируются: строки в порядке первичного ключа
+		w := doRequest(h, http.MethodGet, "/items/export?columns[]=name&columns[]=price&sorters[0][field]=name&sorters[0][order]=asc", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "name,price\nb,10\nc,20\na,30\n", w.Body.String())
+
+		w = doRequest(h, http.MethodGet, "/items/export?format=ndjson&columns[]=name&filters[0][field]=price&filters[0][operator]=gte&filters[0][value]=20", "")
+		assert.Equal(t, "{\"name\":\"c\"}\n{\"name\":\"a\"}\n", w.Body.String())
+
+		w = doRequest(h, http.MethodGet, "/items/export?columns[]=secret", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// NDJSON сохраняет выбранный порядок колонок
+		w = doRequest(h, http.MethodGet, "/items/export?format=ndjson&columns[]=price&columns[]=name&filters[0][field]=name&filters[0][operator]=eq&filters[0][value]=b", "")
+		assert.Equal(t, "{\"price\":10,\"name\":\"b\"}\n", w.Body.String())
+	}
+}
+
+// TestExport_FormulaInjection — строки, похожие на формулы, экранируются в CSV и XLSX
+func TestExport_FormulaInjection(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Create(&TestItem{Name: "=HYPERLINK(\"http://evil\")", Price: -5}).Error; err != nil {
+		t.Fatal(err)
+	}
+	h := ChiExportT[TestItem, uint](newItemRepo(db), Identity[TestItem], ExportOptions{})
+	w := doRequest(h, http.MethodGet, "/export?columns[]=name&columns[]=price", "")
+	assert.Equal(t, "name,price\n\"'=HYPERLINK(\"\"http://evil\"\")\",-5\n", w.Body.String())
+
+	w = doRequest(h, http.MethodGet, "/export?format=xlsx&columns[]=name", "")
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, _ := io.ReadAll(f)
+	assert.Equal(t, true, strings.Contains(string(sheet), `<t xml:space="preserve">&#39;=HYPERLINK(&#34;http://evil&#34;)</t>`))
+}
+
+// TestExport_Abort — ошибка после начала ответа обрывает соединение, клиент не получает «целый» файл
+func TestExport_Abort(t *testing.T) {
+	db := newTestDB(t)
+	seedItems(t, db, "a", "b", "c")
+	repo := newItemRepo(db)
+	failing := func(_ context.Context, it TestItem) (TestItem, error) {
+		if it.Name == "c" {
+			return it, errors.New("boom")
+		}
+		return it, nil
+	}
+
+	r := chi.NewRouter()
+	r.Get("/items/export", ChiExportT[TestItem, uint](repo, failing, ExportOptions{BatchSize: 2}))
+	g := gin.New()
+	g.GET("/items/export", GinExportT[TestItem, uint](repo, failing, ExportOptions{BatchSize: 2}))
+
+	for _, h := range []http.Handler{r, g} {
+		srv := httptest.NewServer(h)
+		resp, err := http.Get(srv.URL + "/items/export")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected truncated response, got %v", err)
+		}
+		assert.Equal(t, true, strings.HasPrefix(string(body), "id,name,price\n1,a,10\n2,b,20\n"))
+		_ = resp.Body.Close()
+		srv.Close()
+
+		// соединение не захватить (как в HTTP/2) — ошибка в трейлере
+		w := doRequest(h, http.MethodGet, "/items/export", "")
+		assert.Equal(t, "boom", w.Result().Trailer.Get(ExportErrorTrailer))
+	}
+}
+
+type itemIn struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+func (in *itemIn) Validate() error {
+	if in.Price < 0 {
+		return errors.New("price must not be negative")
+	}
+	return nil
+}
+
+func itemFromIn(_ context.Context, in itemIn) (TestItem, error) {
+	return TestItem{Name: in.Name, Price: in.Price}, nil
+}
+
+func countItems(db *gorm.DB) int64 {
+	var n int64
+	db.Model(&TestItem{}).Count(&n)
+	return n
+}
+
+func TestImport(t *testing.T) {
+	db := newTestDB(t)
+	repo := newItemRepo(db)
+
+	r := chi.NewRouter()
+	r.Post("/items/import", ChiImportT[TestItem, uint](repo, itemFromIn, ImportOptions{BatchSize: 2}))
+	g := gin.New()
+	g.POST("/items/import", GinImportT[TestItem, uint](repo, itemFromIn, ImportOptions{BatchSize: 2}))
+
+	for _, h := range []http.Handler{r, g} {
+		db.Where("1 = 1").Delete(&TestItem{})
+
+		// одна невалидная строка — ничего не пишется, в отчёте номер строки файла
+		w := doRequest(h, http.MethodPost, "/items/import?format=csv", "name,price\na,1\nb,-1\nc,x\n")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		var report ImportReport
+		_ = json.Unmarshal(w.Body.Bytes(), &report)
+		assert.Equal(t, 3, report.Total)
+		assert.Equal(t, 1, report.Valid)
+		assert.Equal(t, 2, len(report.Errors))
+		assert.Equal(t, 3, report.Errors[0].Row)
+		assert.Equal(t, 4, report.Errors[1].Row)
+		assert.Equal(t, int64(0), countItems(db))
+
+		w = doRequest(h, http.MethodPost, "/items/import?format=csv&dryRun=true", "name,price\na,1\nb,2\n")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(0), countItems(db))
+
+		w = doRequest(h, http.MethodPost, "/items/import?format=csv", "name,price\na,1\nb,2\nc,3\n")
+		assert.Equal(t, http.StatusOK, w.Code)
+		report = ImportReport{}
+		_ = json.Unmarshal(w.Body.Bytes(), &report)
+		assert.Equal(t, int64(3), report.Imported)
+		assert.Equal(t, int64(3), countItems(db))
+
+		// NDJSON из multipart-поля file, формат — по расширению
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", "items.ndjson")
+		_, _ = io.WriteString(fw, "{\"name\":\"d\",\"price\":4}\n{\"name\":\"e\",\"unknown\":1}\n")
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/items/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		report = ImportReport{}
+		_ = json.Unmarshal(w.Body.Bytes(), &report)
+		assert.Equal(t, 2, report.Errors[0].Row)
+		assert.Equal(t, int64(3), countItems(db))
+
+		w = doRequest(h, http.MethodPost, "/items/import?format=xml", "<a/>")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
+// TestImport_Columns — импорт прямо в модель требует списка колонок, остальные (в т.ч. PK) отклоняются
+func TestImport_Columns(t *testing.T) {
+	db := newTestDB(t)
+	repo := newItemRepo(db)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic for model import without Columns")
+			}
+		}()
+		ChiImportT[TestItem, uint](repo, Identity[TestItem], ImportOptions{})
+	}()
+
+	h := ChiImportT[TestItem, uint](repo, Identity[TestItem], ImportOptions{Columns: []string{"name", "price"}})
+	w := doRequest(h, http.MethodPost, "/import?format=csv", "id,name\n100,a\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doRequest(h, http.MethodPost, "/import?format=ndjson", "{\"id\":100,\"name\":\"a\"}\n")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = doRequest(h, http.MethodPost, "/import?format=ndjson", "{\"name\":\"a\",\"price\":1}\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), countItems(db))
+}
+
+// TestTxMiddleware — записи обоих репозиториев коммитятся только при 2xx, иначе откатываются вместе
+func TestTxMiddleware(t *testing.T) {
+	db := newTestDB(t)
+	items := newItemRepo(db)
+	audit := newItemRepo(db) // второй репозиторий в той же транзакции
+	tm := axcrud.NewTxManager(db)
+	handle := func(ctx context.Context, status string) int {
+		if err := items.Create(ctx, &TestItem{Name: "item"}); err != nil {
+			return http.StatusInternalServerError
+		}
+		if err := audit.Create(ctx, &TestItem{Name: "audit"}); err != nil {
+			return http.StatusInternalServerError
+		}
+		if status == "fail" {
+			return http.StatusConflict
+		}
+		return http.StatusCreated
+	}
+
+	r := chi.NewRouter()
+	r.Use(ChiTxMiddleware(tm))
+	r.Post("/items", func(w http.ResponseWriter, req *http.Request) {
+		WriteJSON(w, handle(req.Context(), req.URL.Query().Get("status")), map[string]any{})
+	})
+	g := gin.New()
+	g.Use(GinTxMiddleware(tm))
+	g.POST("/items", func(c *gin.Context) {
+		c.JSON(handle(ginCtx(c), c.Query("status")), gin.H{})
+	})
+
+	for _, h := range []http.Handler{r, g} {
+		db.Where("1 = 1").Delete(&TestItem{})
+		w := doRequest(h, http.MethodPost, "/items?status=fail", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, int64(0), countItems(db))
+
+		w = doRequest(h, http.MethodPost, "/items", "")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, int64(2), countItems(db))
+	}
+}
+
+// TestTxMiddleware_Panic — паника хендлера: откат, а 500 от внешнего recovery доходит до клиента
+func TestTxMiddleware_Panic(t *testing.T) {
+	db := newTestDB(t)
+	items := newItemRepo(db)
+	tm := axcrud.NewTxManager(db)
+	handle := func(ctx context.Context) {
+		_ = items.Create(ctx, &TestItem{Name: "item"})
+		panic("boom")
+	}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer func() {
+				if recover() != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, req)
+		})
+	}, ChiTxMiddleware(tm))
+	r.Post("/items", func(_ http.ResponseWriter, req *http.Request) { handle(req.Context()) })
+	g := gin.New()
+	g.Use(gin.RecoveryWithWriter(io.Discard), GinTxMiddleware(tm))
+	g.POST("/items", func(c *gin.Context) { handle(ginCtx(c)) })
+
+	for _, h := range []http.Handler{r, g} {
+		w := doRequest(h, http.MethodPost, "/items", "")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, int64(0), countItems(db))
+	}
+}
+
+// TestBulk — колонки upsert только из UpsertOptions; ошибка записи — с номером пачки
+func TestBulk(t *testing.T) {
+	db := newTestDB(t)
+	seedItems(t, db, "a")
+	repo := newItemRepo(db)
+	opts := UpsertOptions{ConflictColumns: []string{"id"}, UpdateColumns: []string{"name"}}
+
+	r := chi.NewRouter()
+	r.Post("/items/createMany", ChiCreateMany[TestItem, uint](repo))
+	r.Post("/items/upsert", ChiUpsert[TestItem, uint](repo, opts))
+	g := gin.New()
+	g.POST("/items/createMany", GinCreateMany[TestItem, uint](repo))
+	g.POST("/items/upsert", GinUpsert[TestItem, uint](repo, opts))
+
+	for _, h := range []http.Handler{r, g} {
+		w := doRequest(h, http.MethodPost, "/items/upsert", `{"items": [{"id": 1, "name": "x", "price": 99}], "conflictColumns": ["id"], "updateColumns": ["name"]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var item TestItem
+		db.First(&item, 1)
+		assert.Equal(t, "x", item.Name)
+		assert.Equal(t, 10, item.Price)
+
+		w = doRequest(h, http.MethodPost, "/items/upsert", `{"items": [{"id": 1, "name": "y"}], "updateColumns": ["price"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, true, strings.Contains(w.Body.String(), "update column 'price' is not allowed"))
+
+		// вторая пачка (batchSize 1) упирается в существующий PK — откат всего и номер пачки
+		w = doRequest(h, http.MethodPost, "/items/createMany", `{"items": [{"name": "new"}, {"id": 1, "name": "dup"}], "batchSize": 1}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var out BulkResponse[uint]
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 1, *out.Batch)
+		assert.Equal(t, 1, out.Data[0].Index)
+		assert.Equal(t, int64(1), countItems(db))
+	}
+}
+
+// TestBatch — операции в одной транзакции, $ref на созданный ID; при ошибке — откат всего и номер операции
+func TestBatch(t *testing.T) {
+	db := newTestDB(t)
+	tm := axcrud.NewTxManager(db)
+	reg := NewRegistry().Register("items", NewResource[TestItem, uint](newItemRepo(db), Identity[TestItem]))
+
+	r := chi.NewRouter()
+	r.Post("/_batch", ChiBatch(tm, reg))
+	g := gin.New()
+	g.POST("/_batch", GinBatch(tm, reg))
+
+	for _, h := range []http.Handler{r, g} {
+		db.Where("1 = 1").Delete(&TestItem{})
+		w := doRequest(h, http.MethodPost, "/_batch", `{"operations":[
+			{"op":"create","resource":"items","data":{"name":"a","price":1},"ref":"a"},
+			{"op":"update","resource":"items","id":"$ref:a","data":{"price":2}},
+			{"op":"unknown","resource":"items"}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var resp BatchResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Index == nil {
+			t.Fatalf("expected failing operation index, got %s", w.Body.String())
+		}
+		assert.Equal(t, 2, *resp.Index)
+		assert.Equal(t, int64(0), countItems(db))
+
+		w = doRequest(h, http.MethodPost, "/_batch", `{"operations":[
+			{"op":"create","resource":"items","data":{"name":"a","price":1},"ref":"a"},
+			{"op":"create","resource":"items","data":{"name":"b","price":1},"ref":"b"},
+			{"op":"update","resource":"items","id":"$ref:a","data":{"price":2}},
+			{"op":"delete","resource":"items","id":"$ref:b"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp = BatchResponse{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, 4, len(resp.Data))
+		var left []TestItem
+		db.Find(&left)
+		assert.Equal(t, 1, len(left))
+		assert.Equal(t, 2, left[0].Price)
+
+		w = doRequest(h, http.MethodPost, "/_batch", `{"operations":[{"op":"create","resource":"nope","data":{}}]}`)
+		resp = BatchResponse{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, 0, *resp.Index)
+	}
+}
+
+// readSSE читает из потока n событий и возвращает их id (строки "id: ...")
+func readSSE(t *testing.T, br *bufio.Reader, n int) []string {
+	t.Helper()
+	var ids []string
+	for len(ids) < n {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("sse stream: %v (got %v)", err, ids)
+		}
+		if id, ok := strings.CutPrefix(strings.TrimSpace(line), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// TestEvents — после переподключения с Last-Event-ID приходят пропущенные события, затем живые
+func TestEvents(t *testing.T) {
+	b := axcrud.NewBroker(16)
+	r := chi.NewRouter()
+	r.Get("/items/_events", ChiEvents(b, "items"))
+	g := gin.New()
+	g.GET("/items/_events", GinEvents(b, "items"))
+
+	for _, h := range []http.Handler{r, g} {
+		first := b.Publish(axcrud.Event{Resource: "items", Type: axcrud.EventCreated, IDs: []any{1}})
+		b.Publish(axcrud.Event{Resource: "orders", Type: axcrud.EventCreated, IDs: []any{1}})
+		b.Publish(axcrud.Event{Resource: "items", Type: axcrud.EventUpdated, IDs: []any{1}, TenantID: "t2"}) // чужой тенант
+		missed := b.Publish(axcrud.Event{Resource: "items", Type: axcrud.EventUpdated, IDs: []any{1}})
+		b.Publish(axcrud.Event{Resource: "items", Type: axcrud.EventDeleted, IDs: []any{2}}) // не тот id
+
+		srv := httptest.NewServer(h)
+		reqCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, srv.URL+"/items/_events?ids=1", nil)
+		req.Header.Set("Last-Event-ID", fmt.Sprint(first.ID))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		br := bufio.NewReader(resp.Body)
+		assert.Equal(t, []string{fmt.Sprint(missed.ID)}, readSSE(t, br, 1))
+
+		live := b.Publish(axcrud.Event{Resource: "items", Type: axcrud.EventDeleted, IDs: []any{1}})
+		assert.Equal(t, []string{fmt.Sprint(live.ID)}, readSSE(t, br, 1))
+		cancel()
+		_ = resp.Body.Close()
+		srv.Close()
+	}
+
+	w := doRequest(r, http.MethodGet, "/items/_events?lastEventId=abc", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// wsCall отправляет сообщение и ждёт ответ с тем же requestId (события других подписок пропускаются)
+func wsCall(t *testing.T, ws *websocket.Conn, msg WSMessage) WSReply {
+	t.Helper()
+	if err := websocket.JSON.Send(ws, msg); err != nil {
+		t.Fatal(err)
+	}
+	return wsWait(t, ws, func(r WSReply) bool { return r.RequestID == msg.RequestID && r.Type != "event" })
+}
+
+func wsWait(t *testing.T, ws *websocket.Conn, match func(WSReply) bool) WSReply {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var r WSReply
+		if err := websocket.JSON.Receive(ws, &r); err != nil {
+			t.Fatal(err)
+		}
+		if match(r) {
+			return r
+		}
+	}
+}
+
+// TestWS — CRUD-вызовы и подписка на события через одно WebSocket-соединение
+func TestWS(t *testing.T) {
+	db := newTestDB(t)
+	b := axcrud.NewBroker(16)
+	repo := axcrud.NewGormRepo[TestItem, uint](db, axcrud.RepoConfig{
+		AllowedFilterOps: map[string]axcrud.FieldSet{"name": axcrud.NewFieldSet("eq")},
+	}, axcrud.WithEvents[TestItem, uint](b, "items"))
+	reg := NewRegistry().Register("items", NewResource[TestItem, uint](repo, Identity[TestItem]))
+
+	r := chi.NewRouter()
+	r.Get("/_ws", ChiWS(reg, b, WSOptions{}))
+	g := gin.New()
+	g.GET("/_ws", GinWS(reg, b, WSOptions{}))
+
+	for _, h := range []http.Handler{r, g} {
+		srv := httptest.NewServer(h)
+		wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/_ws"
+		if _, err := websocket.Dial(wsURL, "", "http://evil.example"); err == nil {
+			t.Fatal("expected foreign origin to be rejected")
+		}
+		ws, err := websocket.Dial(wsURL, "", srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reply := wsCall(t, ws, WSMessage{RequestID: "s1", Type: "subscribe", Resource: "items", Types: []string{"created"}})
+		assert.Equal(t, "result", reply.Type)
+
+		reply = wsCall(t, ws, WSMessage{RequestID: "c1", Type: "create", Resource: "items", Data: json.RawMessage(`{"name":"ws","price":5}`)})
+		assert.Equal(t, "result", reply.Type)
+		created, _ := reply.Data.(map[string]any)
+		id := json.RawMessage(fmt.Sprint(created["id"]))
+
+		event := wsWait(t, ws, func(r WSReply) bool { return r.RequestID == "s1" && r.Type == "event" })
+		assert.Equal(t, axcrud.EventCreated, event.Event.Type)
+		assert.Equal(t, created["id"], event.Event.IDs[0])
+
+		reply = wsCall(t, ws, WSMessage{RequestID: "u1", Type: "update", Resource: "items", ID: id, Data: json.RawMessage(`{"price":7}`)})
+		assert.Equal(t, float64(7), reply.Data.(map[string]any)["price"])
+
+		reply = wsCall(t, ws, WSMessage{RequestID: "l1", Type: "getList", Resource: "items",
+			Params: &RefineListRequest{Filters: []RefineFilter{{Field: "name", Operator: "eq", Value: "ws"}}}})
+		assert.Equal(t, int64(1), *reply.Total)
+
+		reply = wsCall(t, ws, WSMessage{RequestID: "d1", Type: "delete", Resource: "items", ID: id})
+		assert.Equal(t, "result", reply.Type)
+		reply = wsCall(t, ws, WSMessage{RequestID: "g1", Type: "getOne", Resource: "items", ID: id})
+		assert.Equal(t, "error", reply.Type)
+
+		reply = wsCall(t, ws, WSMessage{RequestID: "x1", Type: "getOne", Resource: "nope", ID: id})
+		assert.Equal(t, http.StatusNotFound, reply.Status)
+		reply = wsCall(t, ws, WSMessage{RequestID: "s1", Type: "unsubscribe"})
+		assert.Equal(t, "unsubscribed", reply.Type)
+
+		_ = ws.Close()
+		srv.Close()
+	}
+}
+
+// TestVersions — история, diff, состояние на момент и откат через маршруты CreateChiRouter/CreateGinRouter
+func TestVersions(t *testing.T) {
+	db := newTestDB(t)
+	if err := axcrud.MigrateVersions(db); err != nil {
+		t.Fatal(err)
+	}
+	repo := axcrud.NewGormRepo[TestItem, uint](db, axcrud.RepoConfig{}, axcrud.WithVersioning[TestItem, uint]("items"))
+
+	r := chi.NewRouter()
+	r.Route("/items", func(r chi.Router) { CreateChiRouter[TestItem, uint](r, repo) })
+	g := gin.New()
+	CreateGinRouter[TestItem, uint](g.Group("/items"), repo)
+
+	for _, h := range []http.Handler{r, g} {
+		item := TestItem{Name: "v", Price: 1}
+		if err := repo.Create(context.Background(), &item); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		between := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		if _, err := repo.Update(context.Background(), item.ID, map[string]any{"price": 2}); err != nil {
+			t.Fatal(err)
+		}
+		base := fmt.Sprintf("/items/%d", item.ID)
+
+		w := doRequest(h, http.MethodGet, base+"/versions", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var list ListResponse[axcrud.Version[TestItem]]
+		_ = json.Unmarshal(w.Body.Bytes(), &list)
+		assert.Equal(t, int64(2), list.Total)
+
+		w = doRequest(h, http.MethodGet, base+"/versions?from=1&to=2", "")
+		var diff DiffResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &diff)
+		assert.Equal(t, 1, len(diff.Data))
+		assert.Equal(t, "price", diff.Data[0].Field)
+
+		w = doRequest(h, http.MethodGet, base+"/versions?at="+between.UTC().Format(time.RFC3339Nano), "")
+		var asOf OneResponse[TestItem]
+		_ = json.Unmarshal(w.Body.Bytes(), &asOf)
+		assert.Equal(t, 1, asOf.Data.Price)
+
+		w = doRequest(h, http.MethodPost, base+"/revert", `{"version":1}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var stored TestItem
+		db.First(&stored, item.ID)
+		assert.Equal(t, 1, stored.Price)
+
+		w = doRequest(h, http.MethodPost, base+"/revert", `{"version":0}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(h, http.MethodGet, base+"/versions?from=x", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package webcrud
+
+import (
+	"net/http"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+	"github.com/go-chi/chi/v5"
+)
+
+// POST /deliveries/{id}/redeliver — снова поставить доставку вебхука в очередь
+func ChiRedeliver(wh *axcrud.Webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, err := parseID[uint64](chi.URLParam(req, "id"))
+		if err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		if err = wh.Redeliver(req.Context(), id); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, http.StatusOK, AffectedResponse{Data: 1})
+	}
+}
+
+func GinRedeliver(wh *axcrud.Webhooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := parseID[uint64](c.Param("id"))
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err = wh.Redeliver(ginCtx(c), id); err != nil {
+			abortWithError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, AffectedResponse{Data: 1})
+	}
+}
+
+// CreateChiWebhookRouter — управление подписками и журнал доставки.
+// Секрет подписки задаётся при создании/PATCH, но в ответах не возвращается.
+func CreateChiWebhookRouter(r chi.Router, wh *axcrud.Webhooks) {
+	subs, deliveries := wh.Subscriptions(), wh.Deliveries()
+	r.Get("/subscriptions", ChiGetList[axcrud.WebhookSubscription, uint](subs))
+	r.Post("/subscriptions", ChiCreate[axcrud.WebhookSubscription, uint](subs))
+	r.Get("/subscriptions/{id}", ChiGetOne[axcrud.WebhookSubscription, uint](subs))
+	r.Patch("/subscriptions/{id}", ChiUpdate[axcrud.WebhookSubscription, uint](subs))
+	r.Delete("/subscriptions/{id}", ChiDelete[axcrud.WebhookSubscription, uint](subs))
+	r.Get("/deliveries", ChiGetList[axcrud.WebhookDelivery, uint64](deliveries)) // ?filters[0][field]=status&...
+	r.Get("/deliveries/{id}", ChiGetOne[axcrud.WebhookDelivery, uint64](deliveries))
+	r.Post("/deliveries/{id}/redeliver", ChiRedeliver(wh))
+}
+
+func CreateGinWebhookRouter(r *gin.RouterGroup, wh *axcrud.Webhooks) {
+	subs, deliveries := wh.Subscriptions(), wh.Deliveries()
+	r.GET("/subscriptions", GinGetList[axcrud.WebhookSubscription, uint](subs))
+	r.POST("/subscriptions", GinCreate[axcrud.WebhookSubscription, uint](subs))
+	r.GET("/subscriptions/:id", GinGetOne[axcrud.WebhookSubscription, uint](subs))
+	r.PATCH("/subscriptions/:id", GinUpdate[axcrud.WebhookSubscription, uint](subs))
+	r.DELETE("/subscriptions/:id", GinDelete[axcrud.WebhookSubscription, uint](subs))
+	r.GET("/deliveries", GinGetList[axcrud.WebhookDelivery, uint64](deliveries))
+	r.GET("/deliveries/:id", GinGetOne[axcrud.WebhookDelivery, uint64](deliveries))
+	r.POST("/deliveries/:id/redeliver", GinRedeliver(wh))
+}
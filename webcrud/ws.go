@@ -0,0 +1,254 @@
+package webcrud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/axgrid/axcrud"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultWSMaxMessageBytes  = 1 << 20
+	defaultWSMaxSubscriptions = 100
+)
+
+// WSMessage — сообщение клиента. RequestID возвращается в ответе; у подписки он же — её идентификатор
+// (unsubscribe шлётся с тем же requestId).
+type WSMessage struct {
+	RequestID string             `json:"requestId"`
+	Type      string             `json:"type"` // subscribe | unsubscribe | getList | getOne | create | update | delete
+	Resource  string             `json:"resource,omitempty"`
+	ID        json.RawMessage    `json:"id,omitempty"`
+	Data      json.RawMessage    `json:"data,omitempty"`
+	Params    *RefineListRequest `json:"params,omitempty"` // getList
+	// subscribe: фильтры как у SSE и продолжение после lastEventId
+	Types       []string `json:"types,omitempty"`
+	IDs         []any    `json:"ids,omitempty"`
+	LastEventID uint64   `json:"lastEventId,omitempty"`
+}
+
+// WSReply — сообщение сервера: result | error | event | unsubscribed
+type WSReply struct {
+	RequestID string        `json:"requestId,omitempty"`
+	Type      string        `json:"type"`
+	Data      any           `json:"data,omitempty"`
+	Total     *int64        `json:"total,omitempty"`
+	Event     *axcrud.Event `json:"event,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Status    int           `json:"status,omitempty"`
+}
+
+type WSOptions struct {
+	// nil — разрешены запросы без Origin (не браузер) и с Origin того же хоста
+	CheckOrigin      func(req *http.Request) bool
+	MaxMessageBytes  int // по умолчанию 1 MiB
+	MaxSubscriptions int // на соединение, по умолчанию 100
+}
+
+// GET /_ws — одно WebSocket-соединение на много ресурсов из Registry:
+// подписки на события брокера и CRUD-вызовы. Principal/тенант берутся из запроса на upgrade,
+// поэтому действуют те же Policy, FieldAccess и TransformFn, что и в HTTP.
+// broker может быть nil — тогда subscribe недоступен.
+func ChiWS(reg *Registry, broker *axcrud.Broker, opts WSOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		serveWS(req.Context(), w, req, reg, broker, opts)
+	}
+}
+
+func GinWS(reg *Registry, broker *axcrud.Broker, opts WSOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serveWS(ginCtx(c), c.Writer, c.Request, reg, broker, opts)
+	}
+}
+
+func serveWS(ctx context.Context, w http.ResponseWriter, req *http.Request, reg *Registry, broker *axcrud.Broker, opts WSOptions) {
+	srv := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if !checkOrigin(opts, r) {
+				return errors.New("origin is not allowed")
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			c := &wsConn{ws: ws, reg: reg, broker: broker, opts: opts, subs: map[string]context.CancelFunc{}}
+			c.run(ctx)
+		},
+	}
+	srv.ServeHTTP(w, req)
+}
+
+func checkOrigin(opts WSOptions, req *http.Request) bool {
+	if opts.CheckOrigin != nil {
+		return opts.CheckOrigin(req)
+	}
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == req.Host
+}
+
+type wsConn struct {
+	ws     *websocket.Conn
+	reg    *Registry
+	broker *axcrud.Broker
+	opts   WSOptions
+
+	writeMu sync.Mutex
+	subsMu  sync.Mutex
+	subs    map[string]context.CancelFunc
+}
+
+func (c *wsConn) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // закрывает все подписки
+	c.ws.MaxPayloadBytes = defaultWSMaxMessageBytes
+	if c.opts.MaxMessageBytes > 0 {
+		c.ws.MaxPayloadBytes = c.opts.MaxMessageBytes
+	}
+	for {
+		var msg WSMessage
+		if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.send(WSReply{Type: "error", Error: err.Error(), Status: http.StatusBadRequest})
+				continue
+			}
+			return
+		}
+		c.handle(ctx, msg)
+	}
+}
+
+func (c *wsConn) send(r WSReply) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = websocket.JSON.Send(c.ws, r)
+}
+
+func (c *wsConn) fail(requestID string, err error) {
+	c.send(WSReply{RequestID: requestID, Type: "error", Error: err.Error(), Status: errorStatus(err, http.StatusBadRequest)})
+}
+
+func (c *wsConn) handle(ctx context.Context, msg WSMessage) {
+	if msg.Type == "unsubscribe" {
+		c.unsubscribe(msg.RequestID)
+		c.send(WSReply{RequestID: msg.RequestID, Type: "unsubscribed"})
+		return
+	}
+	res, err := c.reg.Get(msg.Resource)
+	if err != nil {
+		c.send(WSReply{RequestID: msg.RequestID, Type: "error", Error: err.Error(), Status: http.StatusNotFound})
+		return
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		err = c.subscribe(ctx, msg)
+		if err == nil {
+			c.send(WSReply{RequestID: msg.RequestID, Type: "result"})
+		}
+	case "getList":
+		var in RefineListRequest
+		if msg.Params != nil {
+			in = *msg.Params
+		}
+		var items any
+		var total int64
+		if items, total, err = res.List(ctx, in); err == nil {
+			c.send(WSReply{RequestID: msg.RequestID, Type: "result", Data: items, Total: &total})
+		}
+	case "getOne", "update", "delete":
+		var id string
+		if id, err = batchID(msg.ID, nil); err != nil {
+			break
+		}
+		var out any
+		switch msg.Type {
+		case "getOne":
+			out, err = res.One(ctx, id)
+		case "update":
+			var patch map[string]any
+			if err = json.Unmarshal(msg.Data, &patch); err == nil {
+				out, err = res.Update(ctx, id, patch)
+			}
+		case "delete":
+			err = res.Delete(ctx, id)
+			out = map[string]any{"id": id}
+		}
+		if err == nil {
+			c.send(WSReply{RequestID: msg.RequestID, Type: "result", Data: out})
+		}
+	case "create":
+		var out any
+		if _, out, err = res.Create(ctx, msg.Data); err == nil {
+			c.send(WSReply{RequestID: msg.RequestID, Type: "result", Data: out})
+		}
+	default:
+		err = fmt.Errorf("unsupported message type '%s'", msg.Type)
+	}
+	if err != nil {
+		c.fail(msg.RequestID, err)
+	}
+}
+
+func (c *wsConn) subscribe(ctx context.Context, msg WSMessage) error {
+	if c.broker == nil {
+		return errors.New("subscriptions are not enabled")
+	}
+	if msg.RequestID == "" {
+		return errors.New("requestId required")
+	}
+	limit := c.opts.MaxSubscriptions
+	if limit <= 0 {
+		limit = defaultWSMaxSubscriptions
+	}
+
+	c.subsMu.Lock()
+	if _, ok := c.subs[msg.RequestID]; ok {
+		c.subsMu.Unlock()
+		return fmt.Errorf("subscription '%s' already exists", msg.RequestID)
+	}
+	if len(c.subs) >= limit {
+		c.subsMu.Unlock()
+		return fmt.Errorf("too many subscriptions (max %d)", limit)
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	c.subs[msg.RequestID] = cancel
+	c.subsMu.Unlock()
+
+	q := url.Values{"types": msg.Types}
+	for _, id := range msg.IDs {
+		q.Add("ids", fmt.Sprint(id))
+	}
+	ch := c.broker.Subscribe(subCtx, msg.LastEventID, eventFilter(ctx, q, msg.Resource))
+	go func() {
+		for e := range ch {
+			c.send(WSReply{RequestID: msg.RequestID, Type: "event", Event: &e})
+		}
+		if subCtx.Err() == nil {
+			// брокер отключил отставшего подписчика — клиент переподпишется с lastEventId
+			c.unsubscribe(msg.RequestID)
+			c.send(WSReply{RequestID: msg.RequestID, Type: "unsubscribed", Error: "subscriber lagged behind"})
+		}
+	}()
+	return nil
+}
+
+func (c *wsConn) unsubscribe(requestID string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if cancel, ok := c.subs[requestID]; ok {
+		cancel()
+		delete(c.subs, requestID)
+	}
+}
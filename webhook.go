@@ -0,0 +1,404 @@
+package axcrud
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookSubscription — подписка партнёра (таблица axcrud_webhooks).
+// Resources/Events — через запятую, пусто — все. TenantID — только события этого тенанта, пусто — любые.
+type WebhookSubscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	URL       string    `json:"url" gorm:"size:2048"`
+	Secret    string    `json:"secret,omitempty" gorm:"size:256"`
+	Resources string    `json:"resources" gorm:"size:1024"`
+	Events    string    `json:"events" gorm:"size:256"`
+	TenantID  string    `json:"tenantId,omitempty" gorm:"size:128;index"`
+	Active    bool      `json:"active" gorm:"default:true;index"` // false при создании не сохранится — выключать через Update
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "axcrud_webhooks"
+}
+
+func (s *WebhookSubscription) BeforeCreate(tx *gorm.DB) error {
+	if s.Secret == "" {
+		return errors.New("webhook secret is required")
+	}
+	return validateWebhookURL(tx, s.URL)
+}
+
+// BeforeUpdate — при Update(patch) модель пустая, URL берётся из patch
+func (s *WebhookSubscription) BeforeUpdate(tx *gorm.DB) error {
+	if patch, ok := tx.Statement.Dest.(map[string]any); ok {
+		for k, v := range patch {
+			if k == "url" || k == "URL" {
+				return validateWebhookURL(tx, fmt.Sprint(v))
+			}
+		}
+		return nil
+	}
+	return validateWebhookURL(tx, s.URL)
+}
+
+// webhookAllowPrivateKey — настройка gorm-сессии репозитория подписок (WebhookOptions.AllowPrivateNetworks)
+const webhookAllowPrivateKey = "axcrud:webhook_allow_private"
+
+// validateWebhookURL проверяет схему и, если не разрешено явно, что хост резолвится
+// только в публичные адреса (защита от SSRF на localhost, 10/8, 169.254.169.254 и т.п.)
+func validateWebhookURL(tx *gorm.DB, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url '%s'", raw)
+	}
+	if allow, _ := tx.Get(webhookAllowPrivateKey); allow == true {
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(tx.Statement.Context, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("invalid webhook url '%s': %w", raw, err)
+	}
+	for _, ip := range ips {
+		if !publicIP(ip.IP) {
+			return fmt.Errorf("webhook url '%s' resolves to non-public address %s", raw, ip.IP)
+		}
+	}
+	return nil
+}
+
+var nonPublicNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),     // «эта» сеть
+	mustCIDR("100.64.0.0/10"), // CGNAT
+	mustCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustCIDR("198.18.0.0/15"), // бенчмарки
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// publicIP — false для loopback, private, link-local, multicast, unspecified и служебных диапазонов
+func publicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicOnlyClient — клиент, который проверяет адрес уже после резолва, при подключении:
+// DNS-ответ, изменившийся после сохранения подписки, и редиректы во внутреннюю сеть не пройдут.
+// Прокси из окружения не используется — иначе проверялся бы адрес прокси.
+func publicOnlyClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("webhook destination %s is not a public address", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+func (s WebhookSubscription) matches(msg OutboxMessage) bool {
+	return s.Active &&
+		listMatches(s.Resources, msg.Resource) &&
+		listMatches(s.Events, string(msg.Type)) &&
+		(s.TenantID == "" || s.TenantID == msg.TenantID)
+}
+
+func listMatches(list, v string) bool {
+	if strings.TrimSpace(list) == "" || strings.TrimSpace(list) == "*" {
+		return true
+	}
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == v {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery — журнал доставки: одна строка на пару (подписка, событие), статус как у outbox
+type WebhookDelivery struct {
+	ID             uint64          `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint            `json:"subscriptionId" gorm:"uniqueIndex:idx_axcrud_webhook_delivery_event,priority:1"`
+	EventKey       string          `json:"eventKey" gorm:"size:64;uniqueIndex:idx_axcrud_webhook_delivery_event,priority:2"`
+	Resource       string          `json:"resource" gorm:"size:128;index"`
+	Type           EventType       `json:"type" gorm:"size:32"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status" gorm:"size:16;index:idx_axcrud_webhook_delivery_poll,priority:1"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt" gorm:"index:idx_axcrud_webhook_delivery_poll,priority:2"`
+	Attempts       int             `json:"attempts"`
+	LockedUntil    *time.Time      `json:"-"`
+	LockedBy       string          `json:"-" gorm:"size:64"`
+	LastError      string          `json:"lastError,omitempty"`
+	LastStatusCode int             `json:"lastStatusCode,omitempty"`
+	LastAttemptAt  *time.Time      `json:"lastAttemptAt,omitempty"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "axcrud_webhook_deliveries"
+}
+
+// MigrateWebhooks создаёт/обновляет таблицы подписок и журнала доставки
+func MigrateWebhooks(db *gorm.DB) error {
+	return db.AutoMigrate(&WebhookSubscription{}, &WebhookDelivery{})
+}
+
+type WebhookOptions struct {
+	RelayOptions
+	// nil — клиент с таймаутом 10s, который не подключается к непубличным адресам.
+	// Свой клиент отвечает за эту проверку сам.
+	Client *http.Client
+	// AllowPrivateNetworks разрешает URL в loopback/private/link-local сетях (тесты, внутренние стенды)
+	AllowPrivateNetworks bool
+}
+
+// Webhooks — исходящие вебхуки. Это Publisher для Relay: событие из outbox раскладывается
+// по подходящим подпискам в журнал доставки, а ProcessBatch/Run отправляют подписанные POST
+// с повторами и экспоненциальной паузой.
+type Webhooks struct {
+	db         *gorm.DB
+	subs       *GormRepo[WebhookSubscription, uint]
+	deliveries *GormRepo[WebhookDelivery, uint64]
+	q          workQueue
+	client     *http.Client
+}
+
+func NewWebhooks(db *gorm.DB, opts WebhookOptions) *Webhooks {
+	client := opts.Client
+	if client == nil && opts.AllowPrivateNetworks {
+		client = &http.Client{Timeout: 10 * time.Second}
+	} else if client == nil {
+		client = publicOnlyClient(10 * time.Second)
+	}
+	eq := NewFieldSet("eq")
+	return &Webhooks{
+		db: db,
+		subs: NewGormRepo[WebhookSubscription, uint](db.Set(webhookAllowPrivateKey, opts.AllowPrivateNetworks), RepoConfig{
+			AllowedFilterOps:  map[string]FieldSet{"active": eq},
+			AllowedSortFields: NewFieldSet("id", "created_at"),
+			WritableFields:    NewFieldSet("url", "secret", "resources", "events", "active"),
+			// подписка принадлежит тенанту из ctx: он проставляется при создании и сужает выборку
+			TenantColumn: "tenant_id",
+			// секрет можно задать, но не прочитать
+			FieldAccess: map[string]FieldAccess{"secret": {ReadRoles: []string{}}},
+		}),
+		deliveries: NewGormRepo[WebhookDelivery, uint64](db, RepoConfig{
+			AllowedFilterOps:  map[string]FieldSet{"subscription_id": eq, "status": eq, "resource": eq},
+			AllowedSortFields: NewFieldSet("id", "created_at"),
+			ContextScopes:     []ContextScope{tenantDeliveries},
+		}),
+		q:      newWorkQueue(opts.RelayOptions),
+		client: client,
+	}
+}
+
+// tenantDeliveries — журнал доставки виден только по подпискам тенанта из ctx
+func tenantDeliveries(ctx context.Context, q *gorm.DB) (*gorm.DB, error) {
+	tenantID, ok := TenantIDFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	subs := q.Session(&gorm.Session{NewDB: true}).Model(&WebhookSubscription{}).
+		Select("id").Where("tenant_id = ?", fmt.Sprint(tenantID))
+	return q.Where("subscription_id IN (?)", subs), nil
+}
+
+// Subscriptions — репозиторий подписок тенанта из ctx (для webcrud-хендлеров управления).
+// Без WithTenantID в ctx — ErrNoTenant; глобальные подписки (пустой TenantID) создаются напрямую через db.
+func (w *Webhooks) Subscriptions() *GormRepo[WebhookSubscription, uint] {
+	return w.subs
+}
+
+// Deliveries — журнал доставки по подпискам тенанта из ctx
+func (w *Webhooks) Deliveries() *GormRepo[WebhookDelivery, uint64] {
+	return w.deliveries
+}
+
+// Publish ставит событие в журнал доставки каждой подходящей подписки.
+// Повторный вызов с тем же IdempotencyKey дублей не создаёт.
+func (w *Webhooks) Publish(ctx context.Context, msg OutboxMessage) error {
+	var subs []WebhookSubscription
+	if err := w.db.WithContext(ctx).Where("active = ?", true).Find(&subs).Error; err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var rows []WebhookDelivery
+	for _, s := range subs {
+		if !s.matches(msg) {
+			continue
+		}
+		rows = append(rows, WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventKey:       msg.IdempotencyKey,
+			Resource:       msg.Resource,
+			Type:           msg.Type,
+			Payload:        payload,
+			Status:         OutboxPending,
+			NextAttemptAt:  now,
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return w.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// Run доставляет вебхуки до отмены ctx
+func (w *Webhooks) Run(ctx context.Context) error {
+	return w.q.run(ctx, w.ProcessBatch)
+}
+
+// ProcessBatch — один проход по журналу: захват, отправка, запись результата
+func (w *Webhooks) ProcessBatch(ctx context.Context) (int, error) {
+	db := w.db.WithContext(ctx)
+	now := time.Now()
+	ids, err := w.q.pick(db, &WebhookDelivery{}, now)
+	if err != nil {
+		return 0, err
+	}
+	processed := 0
+	for _, id := range ids {
+		claimed, err := w.q.claim(db, &WebhookDelivery{}, id, now)
+		if err != nil {
+			return processed, err
+		}
+		if !claimed {
+			continue
+		}
+		var d WebhookDelivery
+		if err = db.First(&d, id).Error; err != nil {
+			return processed, err
+		}
+		var sub WebhookSubscription
+		var code int
+		sendErr := db.First(&sub, d.SubscriptionID).Error
+		if sendErr == nil {
+			code, sendErr = w.send(ctx, sub, d)
+		}
+		extra := map[string]any{"last_status_code": code, "last_attempt_at": time.Now()}
+		if err = w.q.finish(db, &WebhookDelivery{}, id, d.Attempts, sendErr, "delivered_at", extra); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// Redeliver снова ставит доставку в очередь (например, dead после исправления endpoint партнёра)
+func (w *Webhooks) Redeliver(ctx context.Context, id uint64) error {
+	if _, err := w.deliveries.GetOne(ctx, id); errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: delivery %d", ErrNotFound, id)
+	} else if err != nil {
+		return err
+	}
+	res := w.db.WithContext(ctx).Model(&WebhookDelivery{}).Where("id = ?", id).Updates(map[string]any{
+		"status":          OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"locked_until":    nil,
+		"locked_by":       "",
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: delivery %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (w *Webhooks) send(ctx context.Context, sub WebhookSubscription, d WebhookDelivery) (int, error) {
+	ts := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", d.EventKey)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(sub.Secret, ts, d.Payload))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+)
+
+// SignWebhook — HMAC-SHA256(secret, "<timestamp>.<body>") в base64url, как у webcrud.NewIDHasher.
+// Получатель считает то же самое и сравнивает через VerifyWebhook.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	m.Write([]byte("."))
+	m.Write(body)
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// VerifyWebhook проверяет подпись и что timestamp не старше tolerance (защита от повтора)
+func VerifyWebhook(secret string, timestamp int64, body []byte, signature string, tolerance time.Duration) bool {
+	if tolerance > 0 && time.Since(time.Unix(timestamp, 0)).Abs() > tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(SignWebhook(secret, timestamp, body)), []byte(signature))
+}